@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"crawler/crawler"
+)
+
+// Config holds the settings of a crawl job. A config file sets them first,
+// flags given on the command line override the file.
+type Config struct {
+	Seeds     []string `yaml:"seeds" json:"seeds"`
+	SeedsFile string   `yaml:"seeds_file,omitempty" json:"seeds_file,omitempty"`
+
+	Concurrency     int      `yaml:"concurrency" json:"concurrency"`
+	Queue           int      `yaml:"queue" json:"queue"`
+	MaxQueued       int      `yaml:"max_queued" json:"max_queued"`
+	HostConcurrency int      `yaml:"host_concurrency" json:"host_concurrency"`
+	HostDelay       Duration `yaml:"host_delay" json:"host_delay"`
+	Timeout         Duration `yaml:"timeout" json:"timeout"`
+	CrawlTimeout    Duration `yaml:"crawl_timeout" json:"crawl_timeout"`
+	Retries         int      `yaml:"retries" json:"retries"`
+	UserAgent       string   `yaml:"user_agent" json:"user_agent"`
+	// ContentTypes, MaxSize, Truncate and HeadFirst guard fetched bodies.
+	ContentTypes []string `yaml:"content_types" json:"content_types"`
+	MaxSize      int64    `yaml:"max_size" json:"max_size"`
+	Truncate     bool     `yaml:"truncate" json:"truncate"`
+	HeadFirst    bool     `yaml:"head_first" json:"head_first"`
+
+	MaxDepth int      `yaml:"max_depth" json:"max_depth"`
+	Scope    string   `yaml:"scope" json:"scope"`
+	Allow    []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	Deny     []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+	Priority string   `yaml:"priority,omitempty" json:"priority,omitempty"`
+	Follow   []string `yaml:"follow" json:"follow"`
+	// Canonical are the rules applied to urls before the duplicate check.
+	Canonical []string `yaml:"canonical" json:"canonical"`
+	// RelNofollow, NoFollow and NoIndex honor the robots directives of pages.
+	RelNofollow bool `yaml:"rel_nofollow" json:"rel_nofollow"`
+	NoFollow    bool `yaml:"nofollow" json:"nofollow"`
+	NoIndex     bool `yaml:"noindex" json:"noindex"`
+	Sitemaps    bool `yaml:"sitemaps" json:"sitemaps"`
+	// SitemapLimit is a max number of urls from the sitemaps of a site.
+	SitemapLimit int `yaml:"sitemap_limit" json:"sitemap_limit"`
+
+	Job   string `yaml:"job,omitempty" json:"job,omitempty"`
+	State string `yaml:"state" json:"state"`
+	WARC  string `yaml:"warc,omitempty" json:"warc,omitempty"`
+	// WARCMaxSize is a size of a WARC file to start the next one at.
+	WARCMaxSize int64  `yaml:"warc_max_size" json:"warc_max_size"`
+	JSONL       string `yaml:"jsonl,omitempty" json:"jsonl,omitempty"`
+	Graph       string `yaml:"graph,omitempty" json:"graph,omitempty"`
+
+	Metrics   string `yaml:"metrics" json:"metrics"`
+	LogLevel  string `yaml:"log_level" json:"log_level"`
+	LogFormat string `yaml:"log_format" json:"log_format"`
+}
+
+func defaultConfig() Config {
+	follow := make([]string, len(crawler.DefaultFollow))
+	for i, kind := range crawler.DefaultFollow {
+		follow[i] = kind.String()
+	}
+	return Config{
+		Concurrency:     1000,
+		Queue:           10000,
+		MaxQueued:       1000000,
+		HostConcurrency: 4,
+		HostDelay:       Duration(200 * time.Millisecond),
+		Timeout:         Duration(2 * time.Second),
+		CrawlTimeout:    Duration(5 * time.Minute),
+		Retries:         crawler.DefaultRetryPolicy.MaxAttempts,
+		UserAgent:       "crawler",
+		ContentTypes:    []string{"text/html", "application/xhtml+xml"},
+		MaxSize:         10 << 20,
+		Truncate:        true,
+		HeadFirst:       true,
+		MaxDepth:        -1,
+		Scope:           "domain",
+		Follow:          follow,
+		Canonical:       []string{"sort-query", "strip-tracking", "strip-slash"},
+		RelNofollow:     true,
+		NoFollow:        true,
+		NoIndex:         true,
+		SitemapLimit:    50000,
+		State:           "state",
+		WARCMaxSize:     1 << 30,
+		Metrics:         "localhost:8080",
+		LogLevel:        "info",
+		LogFormat:       "text",
+	}
+}
+
+// Duration is a time.Duration written as "2s" in config files.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d *Duration) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *Duration) Set(s string) error {
+	return d.UnmarshalText([]byte(s))
+}
+
+// listFlag is a comma separated list, it replaces the list of the config file.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// options are the flags that are not a part of Config.
+type options struct {
+	config string
+	dryRun bool
+}
+
+func newFlagSet(cfg *Config, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet("crawler", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: crawler [flags] [seed urls...]\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.config, "config", "", "YAML or JSON file with the settings, flags override it")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "print the resolved configuration and exit")
+
+	fs.StringVar(&cfg.SeedsFile, "seeds-file", cfg.SeedsFile, "file with a seed url per line")
+	fs.IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "number of simultaneous requests")
+	fs.IntVar(&cfg.Queue, "queue", cfg.Queue, "number of urls waiting for a free worker")
+	fs.IntVar(&cfg.MaxQueued, "max-queued", cfg.MaxQueued, "max number of found urls waiting for a fetch, more links are skipped, 0 for no limit")
+	fs.IntVar(&cfg.HostConcurrency, "host-concurrency", cfg.HostConcurrency, "number of simultaneous requests to a host")
+	fs.Var(&cfg.HostDelay, "host-delay", "pause between requests to a host")
+	fs.Var(&cfg.Timeout, "timeout", "timeout of a request")
+	fs.Var(&cfg.CrawlTimeout, "crawl-timeout", "timeout of the whole crawl, 0 for none")
+	fs.IntVar(&cfg.Retries, "retries", cfg.Retries, "number of attempts to fetch a url")
+	fs.StringVar(&cfg.UserAgent, "user-agent", cfg.UserAgent, "User-Agent of requests, robots.txt rules are read for it")
+	fs.Var((*listFlag)(&cfg.ContentTypes), "content-types", "comma separated MIME types of bodies to read, e.g. text/*, empty for any")
+	fs.Int64Var(&cfg.MaxSize, "max-size", cfg.MaxSize, "max bytes of a body, 0 for no limit")
+	fs.BoolVar(&cfg.Truncate, "truncate", cfg.Truncate, "cut a larger body to the max size instead of skipping it")
+	fs.BoolVar(&cfg.HeadFirst, "head-first", cfg.HeadFirst, "send HEAD before GET for urls without a page extension")
+
+	fs.IntVar(&cfg.MaxDepth, "depth", cfg.MaxDepth, "max depth of links from the seeds, -1 for no limit")
+	fs.StringVar(&cfg.Scope, "scope", cfg.Scope, "hosts to follow links to: any, host or domain")
+	fs.Var((*listFlag)(&cfg.Allow), "allow", "comma separated host globs or re:<regexp> of urls to follow")
+	fs.Var((*listFlag)(&cfg.Deny), "deny", "comma separated host globs or re:<regexp> of urls to skip")
+	fs.StringVar(&cfg.Priority, "priority", cfg.Priority, "order of fetching: bfs, dfs, links or sitemap, empty for the order of discovery")
+	fs.Var((*listFlag)(&cfg.Follow), "follow", "comma separated kinds of links to fetch: a, area, iframe, refresh, link, img, script, form, css")
+	fs.Var((*listFlag)(&cfg.Canonical), "canonical", "comma separated rules making urls of the same page equal: sort-query, strip-tracking, strip-slash")
+	fs.BoolVar(&cfg.RelNofollow, "rel-nofollow", cfg.RelNofollow, "skip links with rel=nofollow")
+	fs.BoolVar(&cfg.NoFollow, "nofollow", cfg.NoFollow, "skip links of pages with a nofollow meta robots or X-Robots-Tag")
+	fs.BoolVar(&cfg.NoIndex, "noindex", cfg.NoIndex, "keep pages with a noindex meta robots or X-Robots-Tag from the WARC archive")
+	fs.BoolVar(&cfg.Sitemaps, "sitemaps", cfg.Sitemaps, "add urls from robots.txt sitemaps and /sitemap.xml of the seed sites")
+	fs.IntVar(&cfg.SitemapLimit, "sitemap-limit", cfg.SitemapLimit, "max number of urls from the sitemaps of a site, 0 for no limit")
+
+	fs.StringVar(&cfg.Job, "job", cfg.Job, "name of the crawl to persist and resume")
+	fs.StringVar(&cfg.State, "state", cfg.State, "directory of the crawl journals")
+	fs.StringVar(&cfg.WARC, "warc", cfg.WARC, "directory to archive fetched pages to as WARC files")
+	fs.Int64Var(&cfg.WARCMaxSize, "warc-max-size", cfg.WARCMaxSize, "bytes of a WARC file to start the next one at, 0 for a single file")
+	fs.StringVar(&cfg.JSONL, "jsonl", cfg.JSONL, "file to log fetched pages to as JSON lines")
+	fs.StringVar(&cfg.Graph, "graph", cfg.Graph, "file to export the link graph to, .csv, .dot or .graphml")
+
+	fs.StringVar(&cfg.Metrics, "metrics", cfg.Metrics, "address to serve /metrics on, empty to disable")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "log level: debug, info, warn or error")
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "log format: text or json")
+	return fs
+}
+
+// parseConfig resolves the settings from defaults, the config file and flags.
+// Seed urls given as arguments are added to the seeds of the file.
+func parseConfig(args []string) (Config, options, error) {
+	cfg, opts := defaultConfig(), options{}
+	fs := newFlagSet(&cfg, &opts)
+	if err := fs.Parse(args); err != nil {
+		return cfg, opts, err
+	}
+	if opts.config != "" {
+		cfg = defaultConfig()
+		if err := loadConfig(opts.config, &cfg); err != nil {
+			return cfg, opts, err
+		}
+		// flags are parsed again to override the file
+		if err := fs.Parse(args); err != nil {
+			return cfg, opts, err
+		}
+	}
+	cfg.Seeds = append(cfg.Seeds, fs.Args()...)
+	return cfg, opts, nil
+}
+
+func loadConfig(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(strings.NewReader(string(data)))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	} else {
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// seeds returns the seeds of the config and the seeds file.
+func (c Config) seeds() ([]crawler.URL, error) {
+	seeds := make([]crawler.URL, 0, len(c.Seeds))
+	for _, s := range c.Seeds {
+		seeds = append(seeds, crawler.URL(s))
+	}
+	if c.SeedsFile == "" {
+		return seeds, nil
+	}
+	f, err := os.Open(c.SeedsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, crawler.URL(line))
+	}
+	return seeds, scanner.Err()
+}
+
+// resolve returns the config with the seeds of the seeds file
+// added to Seeds, so it is printed with all of them.
+func (c Config) resolve() (Config, error) {
+	seeds, err := c.seeds()
+	if err != nil {
+		return c, err
+	}
+	c.Seeds = make([]string, len(seeds))
+	for i, s := range seeds {
+		c.Seeds[i] = s.String()
+	}
+	c.SeedsFile = ""
+	return c, nil
+}
+
+func (c Config) scopeMode() (crawler.ScopeMode, error) {
+	switch c.Scope {
+	case "any":
+		return crawler.ScopeAny, nil
+	case "host":
+		return crawler.ScopeSameHost, nil
+	case "domain":
+		return crawler.ScopeSameDomain, nil
+	}
+	return crawler.ScopeAny, fmt.Errorf("unknown scope %q", c.Scope)
+}
+
+func (c Config) follow() ([]crawler.LinkKind, error) {
+	kinds := make([]crawler.LinkKind, 0, len(c.Follow))
+	for _, name := range c.Follow {
+		kind, err := crawler.ParseLinkKind(name)
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
+
+// canonicalRules are the names of the rules of crawler.URL.Canonical.
+var canonicalRules = map[string]crawler.CanonicalRule{
+	"sort-query":     crawler.SortQuery,
+	"strip-tracking": crawler.StripTracking,
+	"strip-slash":    crawler.StripTrailingSlash,
+}
+
+func (c Config) canonical() ([]crawler.CanonicalRule, error) {
+	rules := make([]crawler.CanonicalRule, 0, len(c.Canonical))
+	for _, name := range c.Canonical {
+		rule, ok := canonicalRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown canonical rule %q", name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (c Config) scorer() (crawler.Scorer, error) {
+	switch c.Priority {
+	case "":
+		return nil, nil
+	case "bfs":
+		return crawler.BreadthFirst, nil
+	case "dfs":
+		return crawler.DepthFirst, nil
+	case "links":
+		return crawler.MostLinked, nil
+	case "sitemap":
+		return crawler.SitemapPriority(crawler.BreadthFirst), nil
+	}
+	return nil, fmt.Errorf("unknown priority %q", c.Priority)
+}
+
+// print writes the config as YAML.
+func (c Config) print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"crawler/crawler"
+)
+
+func Test_parseConfig(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "crawl.yaml")
+	assert.NoError(t, os.WriteFile(yamlFile, []byte(`
+seeds: [https://a.ru/]
+concurrency: 10
+timeout: 5s
+max_depth: 3
+allow: [a.ru, b.ru]
+`), 0o644))
+	jsonFile := filepath.Join(dir, "crawl.json")
+	assert.NoError(t, os.WriteFile(jsonFile, []byte(`{"seeds": ["https://a.ru/"], "host_delay": "1s", "scope": "host"}`), 0o644))
+	badFile := filepath.Join(dir, "bad.yaml")
+	assert.NoError(t, os.WriteFile(badFile, []byte("depth: 3\n"), 0o644))
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    func(c *Config)
+		wantErr bool
+	}{
+		{
+			name: "по умолчанию",
+			args: []string{"https://a.ru/"},
+			want: func(c *Config) { c.Seeds = []string{"https://a.ru/"} },
+		},
+		{
+			name: "флаги",
+			args: []string{"-depth", "2", "-timeout", "1m", "-deny", "c.ru, d.ru", "-follow", "a,img", "https://a.ru/", "https://b.ru/"},
+			want: func(c *Config) {
+				c.Follow = []string{"a", "img"}
+				c.Seeds = []string{"https://a.ru/", "https://b.ru/"}
+				c.MaxDepth = 2
+				c.Timeout = Duration(time.Minute)
+				c.Deny = []string{"c.ru", "d.ru"}
+			},
+		},
+		{
+			name: "yaml и флаги поверх него",
+			args: []string{"-config", yamlFile, "-concurrency", "20", "-allow", "c.ru", "-max-size", "1024", "-truncate=false", "https://b.ru/"},
+			want: func(c *Config) {
+				c.MaxSize = 1024
+				c.Truncate = false
+				c.Seeds = []string{"https://a.ru/", "https://b.ru/"}
+				c.Concurrency = 20
+				c.Timeout = Duration(5 * time.Second)
+				c.MaxDepth = 3
+				c.Allow = []string{"c.ru"}
+			},
+		},
+		{
+			name: "json",
+			args: []string{"-scope", "any", "-config", jsonFile, "-noindex=false"},
+			want: func(c *Config) {
+				c.NoIndex = false
+				c.Seeds = []string{"https://a.ru/"}
+				c.HostDelay = Duration(time.Second)
+				c.Scope = "any"
+			},
+		},
+		{
+			name:    "неизвестное поле",
+			args:    []string{"-config", badFile},
+			wantErr: true,
+		},
+		{
+			name:    "неверная длительность",
+			args:    []string{"-timeout", "2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := parseConfig(tt.args)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			want := defaultConfig()
+			tt.want(&want)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestConfig_seeds(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "seeds.txt")
+	assert.NoError(t, os.WriteFile(file, []byte("# сиды\nhttps://b.ru/\n\n  https://c.ru/  \n"), 0o644))
+	cfg, _, err := parseConfig([]string{"-seeds-file", file, "https://a.ru/"})
+	assert.NoError(t, err)
+	got, err := cfg.seeds()
+	assert.NoError(t, err)
+	assert.Equal(t, []crawler.URL{"https://a.ru/", "https://b.ru/", "https://c.ru/"}, got)
+}
+
+func TestConfig_canonical(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    crawler.URL
+		wantErr bool
+	}{
+		{name: "по умолчанию", want: "https://habr.com/ru?a=1&b=2"},
+		{name: "без правил", args: []string{"-canonical", ""}, want: "https://habr.com/ru/?b=2&a=1&utm_source=tg"},
+		{name: "слэш сохраняется", args: []string{"-canonical", "sort-query"}, want: "https://habr.com/ru/?a=1&b=2&utm_source=tg"},
+		{name: "неизвестное правило", args: []string{"-canonical", "lower-path"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, _, err := parseConfig(tt.args)
+			assert.NoError(t, err)
+			rules, err := cfg.canonical()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			got, err := crawler.URL("https://habr.com/ru/?b=2&a=1&utm_source=tg").Canonical(rules...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestConfig_print(t *testing.T) {
+	seeds := filepath.Join(t.TempDir(), "seeds.txt")
+	assert.NoError(t, os.WriteFile(seeds, []byte("https://b.ru/\n"), 0o644))
+	cfg, opts, err := parseConfig([]string{"-dry-run", "-host-delay", "1.5s", "-warc-max-size", "1048576", "-seeds-file", seeds, "https://a.ru/"})
+	assert.NoError(t, err)
+	assert.True(t, opts.dryRun)
+	cfg, err = cfg.resolve()
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	assert.NoError(t, cfg.print(&b))
+
+	file := filepath.Join(t.TempDir(), "crawl.yaml")
+	assert.NoError(t, os.WriteFile(file, b.Bytes(), 0o644))
+	assert.Contains(t, b.String(), "host_delay: 1.5s\n")
+	assert.Contains(t, b.String(), "warc_max_size: 1048576\n")
+	assert.Contains(t, b.String(), "seeds:\n  - https://a.ru/\n  - https://b.ru/\n", "seeds of the file are printed")
+	assert.NotContains(t, b.String(), "seeds_file")
+	got, _, err := parseConfig([]string{"-config", file})
+	assert.NoError(t, err)
+	assert.Equal(t, cfg, got, "printed config loads back")
+}
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"time"
 
 	"crawler/crawler"
@@ -9,12 +17,200 @@ import (
 )
 
 func main() {
-	//go http.ListenAndServe("localhost:8080", nil)
-	cl := http.Client{Timeout: 2000 * time.Millisecond}
-	m := crawler.NewMetrics(log.Adapter(log.Printer))
+	cfg, opts, err := parseConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if opts.dryRun {
+		resolved, err := cfg.resolve()
+		if err == nil {
+			err = resolved.print(os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := run(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg Config) error {
+	seeds, err := cfg.seeds()
+	if err != nil {
+		return err
+	}
+	if len(seeds) == 0 {
+		return errors.New("no seed urls, pass them as arguments, with -seeds-file or in the config")
+	}
+	mode, err := cfg.scopeMode()
+	if err != nil {
+		return err
+	}
+	score, err := cfg.scorer()
+	if err != nil {
+		return err
+	}
+	follow, err := cfg.follow()
+	if err != nil {
+		return err
+	}
+	canonical, err := cfg.canonical()
+	if err != nil {
+		return err
+	}
+	level, err := log.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	format, err := log.ParseFormat(cfg.LogFormat)
+	if err != nil {
+		return err
+	}
+	logger := log.New(os.Stderr, level, format)
+
+	cl := http.Client{Timeout: time.Duration(cfg.Timeout), Transport: crawler.UserAgentTransport(nil, cfg.UserAgent)}
+	m := crawler.NewMetrics(logger)
 	defer m.Stop()
-	w := crawler.NewWorkerV2(crawler.WorkerHandler(cl, m), 1000, 300*time.Second, 300*time.Second, m)
-	c := crawler.New(w, m)
-	_, _ = c.Walk([]crawler.URL{"https://ru.wikipedia.org/wiki/%D0%92%D0%B8%D0%BA%D0%B8%D0%BF%D0%B5%D0%B4%D0%B8%D1%8F", "https://habr.com", "https://google.com", "https://habr.com/ru/post/571374/", "https://ru.wikipedia.org"})
+	if cfg.Metrics != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m)
+		go func() {
+			if err := http.ListenAndServe(cfg.Metrics, mux); err != nil {
+				logger.Error("metrics listener", log.F("addr", cfg.Metrics), log.F("err", err))
+			}
+		}()
+	}
+	robots := crawler.NewRobots(cl, cfg.UserAgent, time.Hour)
+	policy := crawler.HostPolicy{
+		MaxConcurrent: cfg.HostConcurrency,
+		Delay:         time.Duration(cfg.HostDelay),
+		CrawlDelay:    robots,
+		Breaker:       crawler.BreakerPolicy{Failures: 5, Cooldown: 10 * time.Second, MaxCooldown: 2 * time.Minute},
+		MaxQueued:     cfg.Queue,
+	}
+	retry := crawler.DefaultRetryPolicy
+	retry.MaxAttempts = cfg.Retries
+	content := crawler.ContentPolicy{
+		Types:     cfg.ContentTypes,
+		MaxSize:   cfg.MaxSize,
+		Truncate:  cfg.Truncate,
+		HeadFirst: cfg.HeadFirst,
+	}
+	w := crawler.NewHostScheduler(crawler.WorkerHandler(cl, m, crawler.WithRetryPolicy(retry), crawler.WithLogger(logger), crawler.WithContentPolicy(content)), policy, func(fn crawler.WorkerFunc) crawler.Worker {
+		return crawler.NewWorkerV2(fn, cfg.Concurrency, cfg.Queue, m, logger)
+	})
+	scope, err := crawler.NewScope(mode, cfg.Allow, cfg.Deny)
+	if err != nil {
+		return err
+	}
+	opts := []crawler.Option{
+		crawler.WithRobots(robots),
+		crawler.WithScope(scope),
+		crawler.WithCanonicalRules(canonical...),
+		crawler.WithMaxDepth(cfg.MaxDepth),
+		crawler.WithMaxQueued(cfg.MaxQueued),
+		crawler.WithFollow(follow...),
+		crawler.WithDirectives(crawler.DirectivePolicy{
+			RelNofollow: cfg.RelNofollow,
+			NoFollow:    cfg.NoFollow,
+			NoIndex:     cfg.NoIndex,
+			UserAgent:   cfg.UserAgent,
+		}),
+	}
+	if score != nil {
+		opts = append(opts, crawler.WithPriority(score))
+	}
+	if cfg.Sitemaps {
+		opts = append(opts, crawler.WithSitemaps(crawler.NewSitemaps(cl, robots, cfg.SitemapLimit)))
+	}
+	if cfg.Job != "" {
+		journal, err := crawler.OpenJournal(cfg.State, cfg.Job)
+		if err != nil {
+			return err
+		}
+		defer journal.Close()
+		opts = append(opts, crawler.WithJournal(journal))
+	}
+	if cfg.WARC != "" {
+		warc, err := crawler.NewWARCWriter(cfg.WARC, "crawl", cfg.WARCMaxSize)
+		if err != nil {
+			return err
+		}
+		defer warc.Close()
+		opts = append(opts, crawler.WithSinks(warc))
+	}
+	if cfg.JSONL != "" {
+		f, err := os.Create(cfg.JSONL)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		opts = append(opts, crawler.WithSinks(crawler.NewJSONLWriter(f)))
+	}
+	graph := crawler.NewLinkGraph(canonical...)
+	if cfg.Graph != "" {
+		opts = append(opts, crawler.WithSinks(graph))
+	}
+	c := crawler.New(w, m, opts...)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if cfg.CrawlTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(cfg.CrawlTimeout))
+		defer cancel()
+	}
+	summary, err := c.Walk(ctx, seeds)
 	m.Print()
+	for _, st := range w.Stats() {
+		fmt.Printf("%s: requests %d, errors %.0f%%, p50 %v, p95 %v, bytes %d, last status %d, paused %v\n",
+			st.Host, st.Requests, st.ErrorRate()*100, st.P50, st.P95, st.Bytes, st.LastStatus, st.Paused)
+	}
+	fmt.Println(summary)
+	if cfg.Graph != "" {
+		if err := exportGraph(graph, cfg.Graph); err != nil {
+			return err
+		}
+	}
+	// the crawl timeout and an interrupt only stop the walk
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+type graphWriter interface {
+	WriteCSV(w io.Writer) error
+	WriteDOT(w io.Writer) error
+	WriteGraphML(w io.Writer) error
+}
+
+func exportGraph(graph graphWriter, path string) error {
+	var write func(w io.Writer) error
+	switch filepath.Ext(path) {
+	case ".csv":
+		write = graph.WriteCSV
+	case ".dot", ".gv":
+		write = graph.WriteDOT
+	case ".graphml":
+		write = graph.WriteGraphML
+	default:
+		return fmt.Errorf("unknown graph format of %s", path)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// CanonicalRule is an optional rewrite applied by URL.Canonical
+// after the base normalization.
+type CanonicalRule func(u *url.URL)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// TrackingParams are query parameters that don't change the page content.
+var TrackingParams = []string{"utm_*", "gclid", "fbclid", "yclid", "_openstat"}
+
+// Canonical lowercases scheme and host, drops a default port and a fragment,
+// resolves dot segments of the path and then applies rules.
+func (u URL) Canonical(rules ...CanonicalRule) (URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(u.String()))
+	if err != nil {
+		return u, err
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	if port := parsed.Port(); port != "" && defaultPorts[parsed.Scheme] == port {
+		parsed.Host = strings.TrimSuffix(parsed.Host, ":"+port)
+	}
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	escaped := removeDotSegments(parsed.EscapedPath())
+	if path, err := url.PathUnescape(escaped); err == nil {
+		parsed.Path, parsed.RawPath = path, escaped
+	}
+	if parsed.Path == "" && parsed.Host != "" {
+		parsed.Path = "/"
+	}
+	for _, rule := range rules {
+		rule(parsed)
+	}
+	return URL(parsed.String()), nil
+}
+
+// SortQuery orders query parameters by name, keeping the order of values.
+func SortQuery(u *url.URL) {
+	if u.RawQuery == "" {
+		return
+	}
+	pairs := strings.Split(u.RawQuery, "&")
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return queryKey(pairs[i]) < queryKey(pairs[j])
+	})
+	u.RawQuery = strings.Join(pairs, "&")
+}
+
+// StripParams removes query parameters by name. A name ending with '*'
+// matches parameters by prefix.
+func StripParams(names ...string) CanonicalRule {
+	return func(u *url.URL) {
+		if u.RawQuery == "" {
+			return
+		}
+		pairs := strings.Split(u.RawQuery, "&")
+		kept := pairs[:0]
+		for _, pair := range pairs {
+			if pair == "" || matchParam(names, queryKey(pair)) {
+				continue
+			}
+			kept = append(kept, pair)
+		}
+		u.RawQuery = strings.Join(kept, "&")
+		u.ForceQuery = false
+	}
+}
+
+// StripTracking removes TrackingParams.
+func StripTracking(u *url.URL) {
+	StripParams(TrackingParams...)(u)
+}
+
+// StripTrailingSlash treats "/path/" and "/path" as the same page.
+func StripTrailingSlash(u *url.URL) {
+	if len(u.Path) > 1 && strings.HasSuffix(u.Path, "/") {
+		u.Path = strings.TrimRight(u.Path, "/")
+		u.RawPath = strings.TrimRight(u.RawPath, "/")
+		if u.Path == "" {
+			u.Path, u.RawPath = "/", ""
+		}
+	}
+}
+
+func queryKey(pair string) string {
+	key, _, _ := strings.Cut(pair, "=")
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
+
+func matchParam(names []string, key string) bool {
+	for _, name := range names {
+		if strings.HasSuffix(name, "*") {
+			if strings.HasPrefix(key, name[:len(name)-1]) {
+				return true
+			}
+			continue
+		}
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDotSegments implements RFC 3986 section 5.2.4.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	out := make([]string, 0, len(segments))
+	for i, s := range segments {
+		last := i == len(segments)-1
+		switch s {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 || (len(out) == 1 && out[0] != "") {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, s)
+		}
+	}
+	result := strings.Join(out, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package crawler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURL_Canonical(t *testing.T) {
+	tests := []struct {
+		name  string
+		url   URL
+		rules []CanonicalRule
+		want  URL
+	}{
+		{name: "схема и хост в нижнем регистре", url: "HTTPS://Habr.COM/ru/", want: "https://habr.com/ru/"},
+		{name: "фрагмент отбрасывается", url: "https://habr.com/ru/#comments", want: "https://habr.com/ru/"},
+		{name: "порт по умолчанию", url: "https://habr.com:443/ru", want: "https://habr.com/ru"},
+		{name: "порт http по умолчанию", url: "http://habr.com:80", want: "http://habr.com/"},
+		{name: "нестандартный порт", url: "http://habr.com:8080/ru", want: "http://habr.com:8080/ru"},
+		{name: "точечные сегменты", url: "https://example.com/a/./b/../c/", want: "https://example.com/a/c/"},
+		{name: "выход за корень", url: "https://example.com/../a", want: "https://example.com/a"},
+		{name: "кодирование пути сохраняется", url: "https://example.com/a%2Fb/../c", want: "https://example.com/c"},
+		{name: "путь не меняет регистр", url: "https://example.com/Wiki/Foo", want: "https://example.com/Wiki/Foo"},
+		{
+			name:  "сортировка параметров",
+			url:   "https://example.com/?b=2&a=1&b=1",
+			rules: []CanonicalRule{SortQuery},
+			want:  "https://example.com/?a=1&b=2&b=1",
+		},
+		{
+			name:  "удаление трекинговых параметров",
+			url:   "https://example.com/post?utm_source=tg&id=1&utm_medium=social&fbclid=x",
+			rules: []CanonicalRule{StripTracking},
+			want:  "https://example.com/post?id=1",
+		},
+		{
+			name:  "только трекинговые параметры",
+			url:   "https://example.com/post?utm_source=tg",
+			rules: []CanonicalRule{StripTracking},
+			want:  "https://example.com/post",
+		},
+		{
+			name:  "завершающий слэш",
+			url:   "https://habr.com/ru/",
+			rules: []CanonicalRule{StripTrailingSlash},
+			want:  "https://habr.com/ru",
+		},
+		{
+			name:  "без завершающего слэша та же страница",
+			url:   "https://habr.com/ru",
+			rules: []CanonicalRule{StripTrailingSlash},
+			want:  "https://habr.com/ru",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.url.Canonical(tt.rules...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestURL_CanonicalInvalid(t *testing.T) {
+	_, err := URL("http://[::1").Canonical()
+	assert.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// SkipReason tells why the body of a response was not read.
+type SkipReason string
+
+const (
+	SkipContentType SkipReason = "content type"
+	SkipTooLarge    SkipReason = "too large"
+)
+
+// ContentPolicy guards the bodies read by WorkerHandler.
+type ContentPolicy struct {
+	// Types are MIME types of bodies that are read, e.g. "text/html" or
+	// "text/*". Any type is read when it is empty or the response has
+	// no Content-Type.
+	Types []string
+	// MaxSize is a max number of body bytes, 0 for no limit.
+	MaxSize int64
+	// Truncate keeps the first MaxSize bytes of a larger body, without it
+	// the body is skipped.
+	Truncate bool
+	// HeadFirst sends HEAD before GET for urls without a page extension
+	// like .html, and doesn't GET a body that would be skipped.
+	HeadFirst bool
+}
+
+// WithContentPolicy limits the bodies WorkerHandler reads. A skipped body
+// is closed without reading, the result tells the reason.
+func WithContentPolicy(policy ContentPolicy) HandlerOption {
+	return func(c *handlerConfig) {
+		c.content = policy
+	}
+}
+
+// pageExtensions are extensions of urls that are expected to be pages.
+var pageExtensions = map[string]bool{
+	"": true, ".html": true, ".htm": true, ".xhtml": true, ".shtml": true,
+	".php": true, ".asp": true, ".aspx": true, ".jsp": true, ".cgi": true,
+}
+
+// needsHead tells whether the url has an unknown extension to check with HEAD.
+func (p ContentPolicy) needsHead(u URL) bool {
+	if !p.HeadFirst || (len(p.Types) == 0 && p.MaxSize <= 0) {
+		return false
+	}
+	parsed, err := url.Parse(u.String())
+	if err != nil {
+		return false
+	}
+	return !pageExtensions[strings.ToLower(path.Ext(parsed.Path))]
+}
+
+// check returns a reason to skip the body by the response headers.
+func (p ContentPolicy) check(header http.Header, length int64) SkipReason {
+	if len(p.Types) > 0 && header.Get("Content-Type") != "" {
+		mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+		if err != nil || !p.allowed(mediaType) {
+			return SkipContentType
+		}
+	}
+	if p.MaxSize > 0 && length > p.MaxSize && !p.Truncate {
+		return SkipTooLarge
+	}
+	return ""
+}
+
+func (p ContentPolicy) allowed(mediaType string) bool {
+	for _, pattern := range p.Types {
+		if ok, _ := path.Match(strings.ToLower(pattern), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// head asks for the headers of the url. It returns a skipped result
+// when the body would be skipped and false when GET is to be sent.
+func (p ContentPolicy) head(ctx context.Context, client http.Client, u URL) (Result, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return Result{}, false
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return Result{}, false
+	}
+	_ = r.Body.Close()
+	if r.StatusCode < 200 || r.StatusCode >= 300 {
+		return Result{}, false
+	}
+	reason := p.check(r.Header, r.ContentLength)
+	if reason == "" {
+		return Result{}, false
+	}
+	result := NewResult(r)
+	result.URL, result.Body, result.Skipped = u, nil, reason
+	return result, true
+}
+
+// limit applies the policy to the body of the result. A body of a known
+// length is not read here, a larger one is cut to MaxSize on reading.
+// A body of an unknown length is read into memory up to MaxSize to tell
+// whether it is too large, the error of reading it is returned.
+func (p ContentPolicy) limit(result *Result) error {
+	if result.Body == nil {
+		return nil
+	}
+	if reason := p.check(result.Header, result.ContentLength); reason != "" {
+		_ = result.Body.Close()
+		result.Body, result.Skipped = nil, reason
+		return nil
+	}
+	if p.MaxSize <= 0 || (result.ContentLength >= 0 && result.ContentLength <= p.MaxSize) {
+		return nil
+	}
+	if result.ContentLength > p.MaxSize {
+		result.Body, result.Truncated = limitedBody{io.LimitReader(result.Body, p.MaxSize), result.Body}, true
+		return nil
+	}
+	defer result.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(result.Body, p.MaxSize+1))
+	if err != nil {
+		result.Body = nil
+		return err
+	}
+	if int64(len(content)) > p.MaxSize {
+		if !p.Truncate {
+			result.Body, result.Skipped = nil, SkipTooLarge
+			return nil
+		}
+		content, result.Truncated = content[:p.MaxSize], true
+	}
+	result.Body = io.NopCloser(bytes.NewReader(content))
+	return nil
+}
+
+// limitedBody reads a part of the body and closes the whole one.
+type limitedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (b limitedBody) Close() error {
+	return b.body.Close()
+}
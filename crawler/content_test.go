@@ -0,0 +1,232 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkerHandler_ContentPolicy(t *testing.T) {
+	var mu sync.Mutex
+	requests := make([]string, 0)
+	big := strings.Repeat("x", 200)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = fmt.Fprint(w, `<a href="/a">a</a>`)
+		case "/big":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, big)
+		case "/chunked":
+			w.Header().Set("Content-Type", "text/plain")
+			for i := 0; i < 4; i++ {
+				_, _ = fmt.Fprint(w, big[:50])
+				w.(http.Flusher).Flush()
+			}
+		case "/broken":
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = fmt.Fprint(w, big[:50])
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+		case "/movie.mp4", "/data.bin":
+			w.Header().Set("Content-Type", "video/mp4")
+			_, _ = fmt.Fprint(w, big)
+		case "/head.bin":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = fmt.Fprint(w, "ok")
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		policy    ContentPolicy
+		path      string
+		skipped   SkipReason
+		body      string
+		truncated bool
+		failed    bool
+		requests  []string
+	}{
+		{
+			name:     "страница",
+			policy:   ContentPolicy{Types: []string{"text/html"}, MaxSize: 100, HeadFirst: true},
+			path:     "/page.html",
+			body:     `<a href="/a">a</a>`,
+			requests: []string{"GET /page.html"},
+		},
+		{
+			name:     "слишком большая по Content-Length",
+			policy:   ContentPolicy{MaxSize: 100},
+			path:     "/big",
+			skipped:  SkipTooLarge,
+			requests: []string{"GET /big"},
+		},
+		{
+			name:      "обрезается",
+			policy:    ContentPolicy{MaxSize: 100, Truncate: true},
+			path:      "/big",
+			body:      big[:100],
+			truncated: true,
+			requests:  []string{"GET /big"},
+		},
+		{
+			name:     "слишком большая без длины",
+			policy:   ContentPolicy{Types: []string{"text/*"}, MaxSize: 120},
+			path:     "/chunked",
+			skipped:  SkipTooLarge,
+			requests: []string{"GET /chunked"},
+		},
+		{
+			name:     "обрыв без длины",
+			policy:   ContentPolicy{MaxSize: 120},
+			path:     "/broken",
+			failed:   true,
+			requests: []string{"GET /broken"},
+		},
+		{
+			name:     "тип по GET",
+			policy:   ContentPolicy{Types: []string{"text/html"}},
+			path:     "/movie.mp4",
+			skipped:  SkipContentType,
+			requests: []string{"GET /movie.mp4"},
+		},
+		{
+			name:     "тип по HEAD",
+			policy:   ContentPolicy{Types: []string{"text/html"}, HeadFirst: true},
+			path:     "/data.bin",
+			skipped:  SkipContentType,
+			requests: []string{"HEAD /data.bin"},
+		},
+		{
+			name:     "HEAD не поддерживается",
+			policy:   ContentPolicy{Types: []string{"text/html"}, HeadFirst: true},
+			path:     "/head.bin",
+			body:     "ok",
+			requests: []string{"HEAD /head.bin", "GET /head.bin"},
+		},
+		{
+			name:     "без ограничений HEAD не нужен",
+			policy:   ContentPolicy{HeadFirst: true},
+			path:     "/data.bin",
+			body:     big,
+			requests: []string{"GET /data.bin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			requests = requests[:0]
+			mu.Unlock()
+			got := WorkerHandler(http.Client{}, MetricMock{}, WithContentPolicy(tt.policy))(context.Background(), URL(ts.URL+tt.path))
+			mu.Lock()
+			assert.Equal(t, tt.requests, requests)
+			mu.Unlock()
+			if tt.failed {
+				assert.ErrorIs(t, got.Err, ErrNetwork)
+				assert.Nil(t, got.Body)
+				return
+			}
+			assert.NoError(t, got.Err)
+			assert.Equal(t, tt.skipped, got.Skipped)
+			assert.Equal(t, tt.truncated, got.Truncated)
+			if tt.skipped != "" {
+				assert.Nil(t, got.Body)
+			} else if assert.NotNil(t, got.Body) {
+				body, _ := io.ReadAll(got.Body)
+				assert.Equal(t, tt.body, string(body))
+				_ = got.Body.Close()
+			}
+		})
+	}
+}
+
+func Test_processor_walkContentPolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			_, _ = fmt.Fprint(w, `<a href="/movie.mp4">movie</a><a href="/a">a</a><img src="/logo.png">`)
+		case "/a":
+			_, _ = fmt.Fprint(w, `<p>page a</p>`)
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = fmt.Fprint(w, `png`)
+		case "/movie.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+			_, _ = fmt.Fprint(w, strings.Repeat("x", 1000))
+		}
+	}))
+	defer ts.Close()
+
+	policy := ContentPolicy{Types: []string{"text/html"}, MaxSize: 100, HeadFirst: true}
+	w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}, WithContentPolicy(policy)), 2, 100, MetricMock{}, nil)
+	p := New(w, MetricMock{}, WithFollow(LinkAnchor, LinkImage))
+	got, err := p.Walk(context.Background(), []URL{URL(ts.URL + "/")})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got.Fetched)
+	assert.Equal(t, map[SkipReason]int{SkipContentType: 2}, got.Skipped)
+	assert.Contains(t, got.String(), "skipped: 2")
+}
+
+func Test_processor_walkBrokenBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			_, _ = fmt.Fprint(w, `<a href="/cut">cut</a>`)
+		case "/cut":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Length", "1000")
+			_, _ = fmt.Fprint(w, `<p>the body is cut</p>`)
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+		}
+	}))
+	defer ts.Close()
+
+	for _, policy := range []ContentPolicy{{}, {MaxSize: 2000}} {
+		dir := t.TempDir()
+		warc, err := NewWARCWriter(dir, "crawl", 0)
+		if !assert.NoError(t, err) {
+			return
+		}
+		sink := &pageRecorder{}
+		w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}, WithContentPolicy(policy)), 2, 100, MetricMock{}, nil)
+		p := New(w, MetricMock{}, WithSinks(sink, warc))
+		got, err := p.Walk(context.Background(), []URL{URL(ts.URL + "/")})
+		assert.NoError(t, err)
+		assert.NoError(t, warc.Close())
+		assert.Equal(t, 1, got.Fetched)
+		assert.Equal(t, map[FailureClass]int{FailureNetwork: 1}, got.Failures)
+
+		if assert.Contains(t, sink.pages, "/cut") {
+			page := sink.pages["/cut"]
+			assert.ErrorIs(t, page.Err, ErrNetwork)
+			assert.False(t, page.Truncated)
+			assert.Equal(t, "<p>the body is cut</p>", string(page.Content))
+		}
+		files, _ := filepath.Glob(filepath.Join(dir, "*.warc.gz"))
+		if assert.Len(t, files, 1) {
+			for _, record := range readWARC(t, files[0]) {
+				assert.NotEqual(t, ts.URL+"/cut", record.fields["WARC-Target-URI"], "a cut body is not archived")
+			}
+		}
+	}
+}
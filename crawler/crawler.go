@@ -1,25 +1,221 @@
 package crawler
 
 import (
+	"context"
 	"fmt"
-	"io"
-	_ "net/http/pprof"
 	"net/url"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bits-and-blooms/bloom/v3"
-	"golang.org/x/net/html"
 )
 
 type processor struct {
-	worker  Worker
-	metrics Metrics
-	stopped bool
+	worker     Worker
+	metrics    Metrics
+	robots     RobotsChecker
+	scope      ScopeChecker
+	canonical  []CanonicalRule
+	maxDepth   int
+	maxQueued  int
+	maxRunning int
+	mu         sync.Mutex
+	seen       *bloom.BloomFilter
+	seenCount  uint
+	dupCount   int
+	bytes      int64
+	pending    int64
+	idle       chan struct{}
+	idleOnce   *sync.Once
+	shutdown   ShutdownPolicy
+	journal    Journal
+	sinks      []Sink
+	writeErr   error
+	score      Scorer
+	frontier   *frontier
+	sitemaps   SitemapSource
+	extractor  *Extractor
+	follow     map[LinkKind]bool
+	directives DirectivePolicy
+	handlers   []Handler
+}
+
+type Option func(p *processor)
+
+// Journal keeps the frontier of a crawl, so it can be resumed after a restart.
+type Journal interface {
+	// Restore returns the visited set and the tasks that were not done.
+	Restore() (seen []URL, pending []Task)
+	Seen(urls []URL) error
+	Queued(tasks []Task) error
+	Done(u URL) error
+}
+
+// WithPriority orders the urls waiting for the worker by the score,
+// e.g. BreadthFirst or DepthFirst. Without it urls are passed
+// to the worker in the order they are found.
+func WithPriority(score Scorer) Option {
+	return func(p *processor) {
+		p.score = score
+	}
+}
+
+// WithMaxQueued limits the number of urls waiting for the worker.
+// Links found while the limit is reached are skipped. 0 is for no limit.
+func WithMaxQueued(n int) Option {
+	return func(p *processor) {
+		p.maxQueued = n
+	}
+}
+
+// WithMaxHandlers limits the number of results processed at once,
+// GOMAXPROCS*4 without it. While all of them are busy, results are
+// not read and the worker stops taking new tasks.
+func WithMaxHandlers(n int) Option {
+	return func(p *processor) {
+		p.maxRunning = n
+	}
+}
+
+// WithSinks passes every result of the walk to the sinks. The body
+// is read into memory to be shared by sinks and the link extractor.
+func WithSinks(sinks ...Sink) Option {
+	return func(p *processor) {
+		p.sinks = append(p.sinks, sinks...)
+	}
+}
+
+// WithExtractor sets the kinds of links found on pages. Without it
+// links of all kinds are passed to sinks.
+func WithExtractor(extractor *Extractor) Option {
+	return func(p *processor) {
+		p.extractor = extractor
+	}
+}
+
+// WithFollow sets the kinds of links that are fetched, DefaultFollow
+// without it.
+func WithFollow(kinds ...LinkKind) Option {
+	return func(p *processor) {
+		p.follow = make(map[LinkKind]bool, len(kinds))
+		for _, k := range kinds {
+			p.follow[k] = true
+		}
+	}
+}
+
+// WithDirectives sets which of rel="nofollow", meta robots and
+// X-Robots-Tag directives are honored, DefaultDirectivePolicy without it.
+func WithDirectives(policy DirectivePolicy) Option {
+	return func(p *processor) {
+		p.directives = policy
+	}
+}
+
+// SitemapSource lists the urls in the sitemaps of the site of a seed.
+type SitemapSource interface {
+	URLs(ctx context.Context, seed URL) []SitemapURL
+}
+
+// WithSitemaps adds the urls from the sitemaps of the seed sites
+// as extra seeds. Their LastMod and Priority are passed to the Scorer.
+func WithSitemaps(sitemaps SitemapSource) Option {
+	return func(p *processor) {
+		p.sitemaps = sitemaps
+	}
+}
+
+// WithHandlers adds handlers to the pipeline of every result,
+// after the link extraction and before the sinks.
+func WithHandlers(handlers ...Handler) Option {
+	return func(p *processor) {
+		p.handlers = append(p.handlers, handlers...)
+	}
+}
+
+// WithJournal records the frontier to the journal and resumes
+// the crawl from it.
+func WithJournal(journal Journal) Option {
+	return func(p *processor) {
+		p.journal = journal
+	}
+}
+
+// ShutdownPolicy tells what happens to submitted fetches
+// when the context of Walk is cancelled.
+type ShutdownPolicy int
+
+const (
+	// ShutdownDrain stops new submissions and waits for submitted fetches.
+	ShutdownDrain ShutdownPolicy = iota
+	// ShutdownAbort stops new submissions and cancels in-flight fetches.
+	ShutdownAbort
+)
+
+func WithShutdownPolicy(policy ShutdownPolicy) Option {
+	return func(p *processor) {
+		p.shutdown = policy
+	}
+}
+
+type RobotsChecker interface {
+	Allowed(u URL) bool
+}
+
+func WithRobots(robots RobotsChecker) Option {
+	return func(p *processor) {
+		p.robots = robots
+	}
+}
+
+type ScopeChecker interface {
+	Seed(urls []URL)
+	InScope(u URL) bool
+}
+
+// WithScope drops links that are out of scope before they are submitted.
+func WithScope(scope ScopeChecker) Option {
+	return func(p *processor) {
+		p.scope = scope
+	}
+}
+
+// WithCanonicalRules adds optional rules to the url canonicalization
+// that runs before the duplicate check.
+func WithCanonicalRules(rules ...CanonicalRule) Option {
+	return func(p *processor) {
+		p.canonical = append(p.canonical, rules...)
+	}
+}
+
+// WithMaxDepth limits how far from the seeds pages are fetched.
+// Links beyond the limit are not marked as seen, so a url is still
+// fetched when a shorter path leads to it later.
+func WithMaxDepth(depth int) Option {
+	return func(p *processor) {
+		p.maxDepth = depth
+	}
 }
 
 type Worker interface {
+	// Shutdown cancels running tasks and closes results.
 	Shutdown()
-	SubmitTasks(urls []URL) <-chan Result
+	// GracefulShutdown stops accepting tasks, waits for submitted ones and closes results.
+	GracefulShutdown()
+	SubmitTasks(tasks []Task) <-chan Result
+}
+
+// HostGate is a Worker that holds submitted tasks it can't start yet,
+// e.g. while a host is delayed. The frontier keeps the tasks of a host
+// until the worker is ready for it, so they are still ordered by the score
+// and a slow host doesn't hold up the others.
+type HostGate interface {
+	// Ready tells whether the worker would start a task of the host without holding it.
+	Ready(host string) bool
+	// Wake returns a channel signalled when a host may have become ready.
+	Wake() <-chan struct{}
 }
 
 type Metrics interface {
@@ -28,87 +224,312 @@ type Metrics interface {
 	IncSubmitted()
 	IncRequestTimeout()
 	IncDuplicate()
+	IncDisallowed()
+	IncDepthLimited(cnt int)
+	IncOutOfScope()
+	IncRetry()
+	// IncNofollow counts links skipped for a nofollow directive.
+	IncNofollow(cnt int)
+	// IncNoIndex counts pages marked noindex.
+	IncNoIndex()
+	// AddQueued changes the number of urls waiting for a fetch or being fetched.
+	AddQueued(n int)
+	// AddInFlight changes the number of running requests.
+	AddInFlight(n int)
+	// ObserveResult records latency and body size of a response or a failure with status 0.
+	ObserveResult(host string, status int, latency time.Duration, size int64)
 }
 
-func New(worker Worker, metrics Metrics) *processor {
-	p := &processor{worker: worker, metrics: metrics}
-	time.AfterFunc(2*time.Minute, func() {
-		p.stopped = true
-	})
+func New(worker Worker, metrics Metrics, opts ...Option) *processor {
+	p := &processor{worker: worker, metrics: metrics, maxDepth: -1, maxRunning: 4 * runtime.GOMAXPROCS(0), extractor: defaultExtractor, directives: DefaultDirectivePolicy}
+	WithFollow(DefaultFollow...)(p)
+	for _, opt := range opts {
+		opt(p)
+	}
 	return p
 }
 
-func (p *processor) Walk(urls []URL) (int, error) {
-	bFilter := bloom.NewWithEstimates(10000000, 0.0001)
+// Walk crawls from the seed urls until there is nothing left to fetch
+// or ctx is done. In both cases it shuts the worker down and returns
+// after the worker closes its results.
+// It returns ctx.Err() for a cancelled crawl and ErrSeedsFailed when none
+// of the seeds was fetched. A crawl resumed from a journal continues
+// with its pending tasks and doesn't refetch visited urls.
+func (p *processor) Walk(ctx context.Context, seeds []URL) (Summary, error) {
+	start := time.Now()
+	summary := Summary{Failures: make(map[FailureClass]int)}
+	p.seen = bloom.NewWithEstimates(10000000, 0.0001)
+	p.seenCount, p.dupCount, p.bytes, p.writeErr = 0, 0, 0, nil
+	p.pending, p.idle, p.idleOnce = 1, make(chan struct{}), new(sync.Once)
+
+	var visited []URL
+	var resumed []Task
+	if p.journal != nil {
+		visited, resumed = p.journal.Restore()
+		for _, u := range visited {
+			p.seen.Add([]byte(u))
+		}
+		p.seenCount = uint(len(visited))
+	}
+
+	// tasks wait in the frontier, so handlers never block on the worker
+	score := p.score
+	if score == nil {
+		score = discovered
+	}
+	p.frontier = newFrontier(score, p.maxQueued)
+	go p.frontier.dispatch(ctx, p.worker, p.idle, p.metrics)
+
+	go func() {
+		select {
+		case <-p.idle:
+			p.worker.GracefulShutdown()
+		case <-ctx.Done():
+			if p.shutdown == ShutdownAbort {
+				p.worker.Shutdown()
+				return
+			}
+			p.worker.GracefulShutdown()
+		}
+	}()
+
+	if p.scope != nil {
+		p.scope.Seed(seeds)
+	}
+	urls := p.unseen(seeds)
 	//parsedUrls := make([]string, 0)
-	out := p.worker.SubmitTasks(urls)
+	seedsFetched := 0
+	var wg sync.WaitGroup
+	out := p.worker.SubmitTasks(nil)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer p.complete()
+		p.submit(ctx, append(resumed, URLs(p.allowed(p.inScope(urls))).Tasks(0)...))
+		// sitemaps are read while the seeds are crawled
+		if p.sitemaps != nil {
+			p.submitSitemaps(ctx, seeds)
+		}
+	}()
+	// a result is counted after the pipeline, as reading the body may fail
+	var counted sync.Mutex
+	count := func(r Result) {
+		counted.Lock()
+		defer counted.Unlock()
+		if class, failed := classify(r); failed {
+			summary.Failures[class]++
+			return
+		}
+		if r.Skipped == "" {
+			summary.Fetched++
+		} else {
+			if summary.Skipped == nil {
+				summary.Skipped = make(map[SkipReason]int)
+			}
+			summary.Skipped[r.Skipped]++
+		}
+		if r.Depth == 0 && r.Parent == "" {
+			seedsFetched++
+		}
+	}
+	running := make(chan struct{}, p.maxRunning)
 	for r := range out {
 		r := r
+		running <- struct{}{}
+		p.metrics.AddQueued(-1)
+		size := new(int64)
+		if r.Body != nil {
+			r.Body = countingReader{ReadCloser: r.Body, n: size}
+		}
+		wg.Add(1)
 		go func() {
-			pu := ExtractLinks(r.Body)
-			urls := make([]URL, len(pu))
-			for i, url := range pu {
-				if bFilter.Test([]byte(url)) {
-					p.metrics.IncDuplicate()
-					continue
-				}
-				bFilter.Add([]byte(url))
-				urls[i] = URL(url)
+			defer wg.Done()
+			defer p.complete()
+			defer func() { <-running }()
+			page := newPageContext(ctx, r)
+			urls := p.handle(ctx, page)
+			count(page.Result)
+			atomic.AddInt64(&p.bytes, atomic.LoadInt64(size))
+			host := r.FinalURL.Host()
+			if r.FinalURL == "" {
+				host = r.URL.Host()
 			}
-			if !p.stopped {
-				p.worker.SubmitTasks(urls)
+			p.metrics.ObserveResult(host, r.StatusCode, r.Timings.Total, atomic.LoadInt64(size))
+			if p.maxDepth >= 0 && r.Depth+1 > p.maxDepth {
+				p.metrics.IncDepthLimited(len(urls))
+			} else {
+				p.submit(ctx, URLs(p.allowed(p.inScope(p.unseen(urls)))).LinkedFrom(r.FinalURL, r.Depth+1))
+			}
+			if p.journal != nil {
+				p.store("journal", p.journal.Done(r.URL))
 			}
 		}()
 	}
-	actualFpRate := bloom.EstimateFalsePositiveRate(bFilter.Cap(), bFilter.K(), 10000000)
-	println(actualFpRate)
-	return 0, nil
+	wg.Wait()
+
+	p.mu.Lock()
+	summary.Duplicates = p.dupCount
+	summary.BloomFPRate = bloomFPRate(p.seen.Cap(), p.seen.K(), p.seenCount)
+	p.mu.Unlock()
+	summary.Bytes = atomic.LoadInt64(&p.bytes)
+	summary.Duration = time.Since(start)
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	if p.writeErr != nil {
+		return summary, p.writeErr
+	}
+	if len(seeds) > 0 && len(visited) == 0 && seedsFetched == 0 {
+		return summary, fmt.Errorf("%w: %d seeds", ErrSeedsFailed, len(seeds))
+	}
+	return summary, nil
 }
 
-func ExtractLinks(body io.ReadCloser) []string {
-	urls := make([]string, 0)
-	if body == nil {
-		return urls
+// submit queues tasks to the frontier unless the walk is cancelled.
+// Every queued task is pending until its result is processed. The caller
+// is pending itself, so dropping tasks of a full frontier doesn't make
+// the walk idle. The dropped tasks stay queued in the journal and are
+// fetched when the crawl is resumed.
+func (p *processor) submit(ctx context.Context, tasks []Task) {
+	if ctx.Err() != nil {
+		p.metrics.IncSkipped(len(tasks))
+		return
+	}
+	atomic.AddInt64(&p.pending, int64(len(tasks)))
+	p.metrics.AddQueued(len(tasks))
+	if p.journal != nil {
+		p.store("journal", p.journal.Queued(tasks))
+	}
+	if dropped := p.frontier.push(tasks); dropped > 0 {
+		atomic.AddInt64(&p.pending, -int64(dropped))
+		p.metrics.AddQueued(-dropped)
+		p.metrics.IncSkipped(dropped)
 	}
-	defer func() {
-		if err := body.Close(); err != nil {
-			panic(err)
+}
+
+// submitSitemaps submits the urls of the sitemaps of every seed site
+// at the depth of the seeds, with the sitemap as the parent. Sites are
+// read at once and the urls of a site are submitted when it is read.
+func (p *processor) submitSitemaps(ctx context.Context, seeds []URL) {
+	sites := make(map[string]bool)
+	var wg sync.WaitGroup
+	for _, seed := range seeds {
+		parsed, err := url.Parse(seed.String())
+		if err != nil || parsed.Host == "" || sites[parsed.Scheme+"://"+parsed.Host] {
+			continue
 		}
-	}()
-	tokenizer := html.NewTokenizer(body)
-	for {
-		tt := tokenizer.Next()
-		if tt == html.ErrorToken {
-			if tokenizer.Err() == io.EOF {
-				return urls
-			}
-			fmt.Printf("Error next token: %v", tokenizer.Err())
-			return urls
+		sites[parsed.Scheme+"://"+parsed.Host] = true
+		wg.Add(1)
+		go func(seed URL) {
+			defer wg.Done()
+			p.submitSitemap(ctx, p.sitemaps.URLs(ctx, seed))
+		}(seed)
+	}
+	wg.Wait()
+}
+
+func (p *processor) submitSitemap(ctx context.Context, found []SitemapURL) {
+	entries := make(map[URL]SitemapURL)
+	urls := make([]URL, 0)
+	for _, e := range found {
+		c, err := e.URL.Canonical(p.canonical...)
+		if err != nil {
+			p.metrics.IncSkipped(1)
+			continue
 		}
-		tag, hasAttr := tokenizer.TagName()
-		if string(tag) != "a" {
+		entries[c] = e
+		urls = append(urls, c)
+	}
+	urls = p.allowed(p.inScope(p.unseen(urls)))
+	tasks := make([]Task, len(urls))
+	for i, u := range urls {
+		e := entries[u]
+		tasks[i] = Task{URL: u, Parent: e.Sitemap, LastMod: e.LastMod, Priority: e.Priority}
+	}
+	p.submit(ctx, tasks)
+}
+
+// store keeps the first error of the journal or a sink, the walk
+// returns it at the end.
+func (p *processor) store(what string, err error) {
+	if err == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keep(what, err)
+}
+
+func (p *processor) keep(what string, err error) {
+	if err != nil && p.writeErr == nil {
+		p.writeErr = fmt.Errorf("%s: %w", what, err)
+	}
+}
+
+// complete marks one pending task as processed. The walk becomes idle
+// when nothing is pending.
+func (p *processor) complete() {
+	if atomic.AddInt64(&p.pending, -1) == 0 {
+		p.idleOnce.Do(func() {
+			close(p.idle)
+		})
+	}
+}
+
+// unseen canonicalizes urls and drops the ones that were already met.
+func (p *processor) unseen(urls []URL) []URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fresh := make([]URL, 0, len(urls))
+	var dups []URL
+	for _, u := range urls {
+		c, err := u.Canonical(p.canonical...)
+		if err != nil {
+			p.metrics.IncSkipped(1)
 			continue
 		}
-		//fmt.Printf("Tag: %v\n", string(tag))
-		if hasAttr {
-			for {
-				attrKey, attrValue, moreAttr := tokenizer.TagAttr()
-				if string(attrKey) != "href" {
-					break
-				}
-				url, err := url.Parse(string(attrValue))
-				if err != nil || url.Scheme == "" {
-					break
-				}
-				//fmt.Printf("Attr: %v\n", string(attrKey))
-				urls = append(urls, url.String())
-				//fmt.Printf("Attr: %v\n", url)
-				//fmt.Printf("Shema: %v\n", url.Scheme)
-				//fmt.Printf("Attr: %v\n", moreAttr)
-				if !moreAttr {
-					break
-				}
-			}
+		if p.seen.TestAndAdd([]byte(c)) {
+			p.dupCount++
+			p.metrics.IncDuplicate()
+			dups = append(dups, c)
+			continue
+		}
+		p.seenCount++
+		fresh = append(fresh, c)
+	}
+	p.frontier.link(dups)
+	if p.journal != nil {
+		p.keep("journal", p.journal.Seen(fresh))
+	}
+	return fresh
+}
+
+func (p *processor) inScope(urls []URL) []URL {
+	if p.scope == nil {
+		return urls
+	}
+	in := make([]URL, 0, len(urls))
+	for _, u := range urls {
+		if !p.scope.InScope(u) {
+			p.metrics.IncOutOfScope()
+			continue
+		}
+		in = append(in, u)
+	}
+	return in
+}
+
+func (p *processor) allowed(urls []URL) []URL {
+	if p.robots == nil {
+		return urls
+	}
+	allowed := make([]URL, 0, len(urls))
+	for _, u := range urls {
+		if !p.robots.Allowed(u) {
+			p.metrics.IncDisallowed()
+			continue
 		}
+		allowed = append(allowed, u)
 	}
+	return allowed
 }
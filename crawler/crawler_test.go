@@ -1,73 +1,368 @@
 package crawler
 
 import (
-	"reflect"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
-type workerMock struct{}
+type workerMock struct {
+	results map[URL]Result
+	out     chan Result
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+func newWorkerMock(results map[URL]Result) *workerMock {
+	return &workerMock{results: results, out: make(chan Result)}
+}
 
-func (p *workerMock) Shutdown() {}
+func (p *workerMock) Shutdown() {
+	p.GracefulShutdown()
+}
 
-func (p *workerMock) SubmitTasks(urls []URL) <-chan Result {
-	out := make(chan Result)
+func (p *workerMock) GracefulShutdown() {
+	p.once.Do(func() {
+		p.wg.Wait()
+		close(p.out)
+	})
+}
+
+func (p *workerMock) SubmitTasks(tasks []Task) <-chan Result {
+	p.wg.Add(1)
 	go func() {
-		for i := 0; i < len(urls); i++ {
-			out <- ResultOK
+		defer p.wg.Done()
+		for _, task := range tasks {
+			r, ok := p.results[task.URL]
+			if !ok {
+				r = resultOK
+			}
+			r.Depth, r.Parent = task.Depth, task.Parent
+			p.out <- r
 		}
-		close(out)
 	}()
-	return out
+	return p.out
 }
 
-func _Test_processor_walk(t *testing.T) {
+func Test_processor_walk(t *testing.T) {
 	type args struct {
-		urls []URL
+		urls    []URL
+		results map[URL]Result
 	}
 	tests := []struct {
 		name    string
 		args    args
-		want    []Result
-		wantErr bool
+		want    Summary
+		wantErr error
 	}{
 		{
 			name: "nil urls",
 			args: args{
 				urls: nil,
 			},
-			want:    []Result{},
-			wantErr: false,
+			want: Summary{Failures: map[FailureClass]int{}},
 		},
 		{
-			name:    "empty urls",
-			args:    args{urls: []URL{}},
-			want:    []Result{},
-			wantErr: false,
+			name: "empty urls",
+			args: args{urls: []URL{}},
+			want: Summary{Failures: map[FailureClass]int{}},
 		},
 		{
-			name:    "one urls",
-			args:    args{urls: []URL{"https://google.com"}},
-			want:    []Result{ResultOK},
-			wantErr: false,
+			name: "one urls",
+			args: args{urls: []URL{"https://google.com"}},
+			want: Summary{Fetched: 1, Failures: map[FailureClass]int{}},
 		},
 		{
-			name:    "two urls",
-			args:    args{urls: []URL{"https://google.com", "https://yandex.ru"}},
-			want:    []Result{ResultOK, ResultOK},
-			wantErr: false,
+			name: "two urls",
+			args: args{urls: []URL{"https://google.com", "https://yandex.ru"}},
+			want: Summary{Fetched: 2, Failures: map[FailureClass]int{}},
+		},
+		{
+			name: "duplicated urls",
+			args: args{urls: []URL{"https://google.com", "https://Google.com/#top"}},
+			want: Summary{Fetched: 1, Duplicates: 1, Failures: map[FailureClass]int{}},
+		},
+		{
+			name: "one of urls failed",
+			args: args{
+				urls:    []URL{"https://google.com/", "https://yandex.ru/"},
+				results: map[URL]Result{"https://yandex.ru/": resultFAIL},
+			},
+			want: Summary{Fetched: 1, Failures: map[FailureClass]int{FailureServerError: 1}},
+		},
+		{
+			name: "all urls failed",
+			args: args{
+				urls:    []URL{"https://google.com/", "https://yandex.ru/"},
+				results: map[URL]Result{"https://google.com/": resultCANCEL, "https://yandex.ru/": resultFAIL},
+			},
+			want:    Summary{Failures: map[FailureClass]int{FailureServerError: 1, FailureCanceled: 1}},
+			wantErr: ErrSeedsFailed,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			p := New(&workerMock{}, &MetricMock{})
-			got, err := p.Walk(tt.args.urls)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("walk() error = %v, wantErr %v", err, tt.wantErr)
-				return
+			p := New(newWorkerMock(tt.args.results), &MetricMock{})
+			got, err := p.Walk(context.Background(), tt.args.urls)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
 			}
-			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("walk() got = %v, want %v", got, tt.want)
+			assert.Greater(t, got.Duration, time.Duration(0))
+			if len(tt.args.urls) > 0 {
+				assert.Greater(t, got.BloomFPRate, 0.0)
 			}
+			got.Duration, got.BloomFPRate = 0, 0
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func newSiteServer(pages map[string]string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = fmt.Fprint(w, body)
+	}))
+}
+
+func Test_processor_walkSite(t *testing.T) {
+	ts := newSiteServer(map[string]string{
+		"/":  `<a href="/a">a</a><a href="/b">b</a>`,
+		"/a": `<a href="/b">b</a><a href="c">c</a>`,
+		"/b": `<a href="/">root</a>`,
+		"/c": `<a href="/a">a</a><a href="/missing">?</a>`,
+	}, 0)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		maxDepth int
+		want     Summary
+	}{
+		{
+			name:     "обход до конца",
+			maxDepth: -1,
+			want:     Summary{Fetched: 4, Failures: map[FailureClass]int{FailureClientError: 1}, Duplicates: 3},
+		},
+		{
+			name:     "ограничение глубины",
+			maxDepth: 1,
+			want:     Summary{Fetched: 3, Failures: map[FailureClass]int{}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}), 2, 100, MetricMock{}, nil)
+			p := New(w, MetricMock{}, WithMaxDepth(tt.maxDepth))
+			got, err := p.Walk(context.Background(), []URL{URL(ts.URL)})
+			assert.NoError(t, err)
+			assert.Greater(t, got.Bytes, int64(0))
+			got.Duration, got.BloomFPRate, got.Bytes = 0, 0, 0
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_processor_walkDepthPaths(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	pages := map[string]string{
+		"/":      `<a href="/short">short</a><a href="/a">a</a>`,
+		"/a":     `<a href="/b">b</a>`,
+		"/b":     `<a href="/x">x</a>`,
+		"/short": `<a href="/x">x</a>`,
+		"/x":     `x`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	defer ts.Close()
+
+	// the long path to /x is walked first, it reaches /x beyond the limit
+	w := newStepWorker(WorkerHandler(http.Client{}, MetricMock{}))
+	p := New(w, MetricMock{}, WithPriority(DepthFirst), WithJournal(w), WithMaxDepth(2))
+	got, err := p.Walk(context.Background(), []URL{URL(ts.URL + "/")})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, got.Fetched)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/", "/a", "/b", "/short", "/x"}, requested)
+}
+
+func Test_processor_walkCancel(t *testing.T) {
+	ts := newSiteServer(map[string]string{"/": `<a href="/">root</a>`}, 10*time.Second)
+	defer ts.Close()
+
+	for _, policy := range []ShutdownPolicy{ShutdownAbort, ShutdownDrain} {
+		w := NewWorkerV2(WorkerHandler(http.Client{Timeout: 200 * time.Millisecond}, MetricMock{}), 2, 100, MetricMock{}, nil)
+		p := New(w, MetricMock{}, WithShutdownPolicy(policy))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		started := time.Now()
+		_, err := p.Walk(ctx, []URL{URL(ts.URL)})
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(started), time.Second, "walk is stopped by the context")
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		page URL
+		body string
+		want []string
+	}{
+		{
+			name: "абсолютные ссылки",
+			page: "https://example.com/a/b",
+			body: `<a href="https://google.com">g</a><a href="http://yandex.ru/x">y</a>`,
+			want: []string{"https://google.com", "http://yandex.ru/x"},
+		},
+		{
+			name: "относительные ссылки",
+			page: "https://example.com/a/b",
+			body: `<a href="/wiki/Foo">1</a><a href="../post/1">2</a><a href="c?q=1">3</a><a href="//cdn.example.com/x">4</a>`,
+			want: []string{
+				"https://example.com/wiki/Foo",
+				"https://example.com/post/1",
+				"https://example.com/a/c?q=1",
+				"https://cdn.example.com/x",
+			},
+		},
+		{
+			name: "base href",
+			page: "https://example.com/a/b",
+			body: `<head><base target="_blank" href="https://mirror.example.com/root/"></head><a href="page">1</a><a href="/abs">2</a>`,
+			want: []string{"https://mirror.example.com/root/page", "https://mirror.example.com/abs"},
+		},
+		{
+			name: "относительный base href",
+			page: "https://example.com/a/b",
+			body: `<base href="/docs/"><a href="intro">1</a>`,
+			want: []string{"https://example.com/docs/intro"},
+		},
+		{
+			name: "не http ссылки отбрасываются",
+			page: "https://example.com/",
+			body: `<a href="mailto:me@example.com">m</a><a href="javascript:void(0)">j</a>`,
+			want: []string{},
+		},
+		{
+			name: "без адреса страницы остаются только абсолютные",
+			page: "",
+			body: `<a href="/wiki/Foo">1</a><a href="https://google.com">g</a>`,
+			want: []string{"https://google.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractLinks(tt.page, NewContent(tt.body))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_extractLinks(t *testing.T) {
+	body := `<a class="x" href="/a" rel="nofollow  noopener">The <b>first</b>
+	link</a><a href="/b"><img src="b.png"></a><a href="/c"/>tail<a name="top">top</a>`
+	got := extractLinks("https://example.com/", NewContent(body))
+	assert.Equal(t, []Link{
+		{URL: "https://example.com/a", Text: "The first link", Rel: "nofollow noopener"},
+		{URL: "https://example.com/b"},
+		{URL: "https://example.com/c"},
+	}, got)
+}
+
+func TestExtractor(t *testing.T) {
+	body := `<html><head>
+<meta http-equiv="Refresh" content="5; URL='/next'">
+<link rel="stylesheet" href="/main.css"><link rel="alternate" hreflang="en" href="/en/">
+<style>body { background: url("/bg.png") } .x { background: URL( 'x.svg' ) }</style>
+<script>var a = "<a href='/no'>";</script><script type="module" src="/app.js"></script>
+</head><body>
+<img alt="logo" src="/logo.png" srcset="/logo-2x.png 2x, /logo-3x.png 3x">
+<map><area shape="rect" href="/area" rel="help"></map>
+<iframe width="1" src="https://video.example.org/embed"></iframe>
+<form method="get" action="/search"></form>
+<div style="background-image:url(/div.jpg)"><a id="x" href="/a">a</a></div>
+</body></html>`
+	got := NewExtractor().Extract("https://example.com/", NewContent(body))
+	assert.Equal(t, []Link{
+		{URL: "https://example.com/next", Kind: LinkRefresh},
+		{URL: "https://example.com/main.css", Rel: "stylesheet", Kind: LinkHead},
+		{URL: "https://example.com/en/", Rel: "alternate", Kind: LinkHead},
+		{URL: "https://example.com/bg.png", Kind: LinkCSS},
+		{URL: "https://example.com/x.svg", Kind: LinkCSS},
+		{URL: "https://example.com/app.js", Kind: LinkScript},
+		{URL: "https://example.com/logo.png", Kind: LinkImage},
+		{URL: "https://example.com/logo-2x.png", Kind: LinkImage},
+		{URL: "https://example.com/logo-3x.png", Kind: LinkImage},
+		{URL: "https://example.com/area", Rel: "help", Kind: LinkArea},
+		{URL: "https://video.example.org/embed", Kind: LinkFrame},
+		{URL: "https://example.com/search", Kind: LinkForm},
+		{URL: "https://example.com/div.jpg", Kind: LinkCSS},
+		{URL: "https://example.com/a", Text: "a", Kind: LinkAnchor},
+	}, got)
+
+	got = NewExtractor(LinkAnchor, LinkFrame).Extract("https://example.com/", NewContent(body))
+	assert.Equal(t, []Link{
+		{URL: "https://video.example.org/embed", Kind: LinkFrame},
+		{URL: "https://example.com/a", Text: "a", Kind: LinkAnchor},
+	}, got)
+
+	kind, err := ParseLinkKind("IMG")
+	assert.NoError(t, err)
+	assert.Equal(t, LinkImage, kind)
+	assert.Equal(t, "img", kind.String())
+	_, err = ParseLinkKind("video")
+	assert.Error(t, err)
+}
+
+func Test_processor_walkFollow(t *testing.T) {
+	ts := newSiteServer(map[string]string{
+		"/":         `<img src="/logo.png"><iframe src="/frame"></iframe><a href="/a">a</a>`,
+		"/a":        `page a`,
+		"/frame":    `frame`,
+		"/logo.png": `png`,
+	}, 0)
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		fetched int
+	}{
+		{name: "по умолчанию страницы", fetched: 3},
+		{name: "только ссылки", opts: []Option{WithFollow(LinkAnchor)}, fetched: 2},
+		{name: "и картинки", opts: []Option{WithFollow(append(DefaultFollow, LinkImage)...)}, fetched: 4},
+		{name: "извлекаются только картинки", opts: []Option{WithExtractor(NewExtractor(LinkImage)), WithFollow(LinkImage)}, fetched: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}), 2, 100, MetricMock{}, nil)
+			got, err := New(w, MetricMock{}, tt.opts...).Walk(context.Background(), []URL{URL(ts.URL + "/")})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.fetched, got.Fetched)
 		})
 	}
 }
@@ -0,0 +1,103 @@
+package crawler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RobotsDirectives are the indexing directives of a page from
+// <meta name="robots"> or the X-Robots-Tag header.
+type RobotsDirectives struct {
+	NoIndex  bool
+	NoFollow bool
+}
+
+// ParseRobotsDirectives reads a comma separated list of directives,
+// e.g. "noindex, nofollow". "none" is both of them.
+func ParseRobotsDirectives(s string) RobotsDirectives {
+	var d RobotsDirectives
+	for _, v := range strings.Split(s, ",") {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "noindex":
+			d.NoIndex = true
+		case "nofollow":
+			d.NoFollow = true
+		case "none":
+			d.NoIndex, d.NoFollow = true, true
+		}
+	}
+	return d
+}
+
+func (d RobotsDirectives) merge(other RobotsDirectives) RobotsDirectives {
+	return RobotsDirectives{NoIndex: d.NoIndex || other.NoIndex, NoFollow: d.NoFollow || other.NoFollow}
+}
+
+// robotsTagOptions are directives of X-Robots-Tag with a value after a colon,
+// any other name before a colon is a robot.
+var robotsTagOptions = map[string]bool{
+	"unavailable_after": true,
+	"max-snippet":       true,
+	"max-image-preview": true,
+	"max-video-preview": true,
+}
+
+// HeaderRobotsDirectives reads X-Robots-Tag for all robots and for userAgent,
+// e.g. "noindex" or "crawler: nofollow".
+func HeaderRobotsDirectives(h http.Header, userAgent string) RobotsDirectives {
+	var d RobotsDirectives
+	agent := strings.ToLower(userAgent)
+	for _, value := range h.Values("X-Robots-Tag") {
+		if name, rest, ok := strings.Cut(value, ":"); ok && !strings.Contains(name, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !robotsTagOptions[name] {
+				if name == agent {
+					d = d.merge(ParseRobotsDirectives(rest))
+				}
+				continue
+			}
+		}
+		d = d.merge(ParseRobotsDirectives(value))
+	}
+	return d
+}
+
+// DirectivePolicy tells which robots directives of pages are honored.
+type DirectivePolicy struct {
+	// RelNofollow skips links with rel="nofollow".
+	RelNofollow bool
+	// NoFollow skips all links of a page marked nofollow by a meta tag or X-Robots-Tag.
+	NoFollow bool
+	// NoIndex marks a page noindex for the sinks, e.g. WARC doesn't store it.
+	NoIndex bool
+	// UserAgent adds the directives addressed to this robot, e.g.
+	// <meta name="crawler">, to the ones for all robots.
+	UserAgent string
+}
+
+// DefaultDirectivePolicy honors all directives for all robots.
+var DefaultDirectivePolicy = DirectivePolicy{RelNofollow: true, NoFollow: true, NoIndex: true}
+
+// page returns the directives of a page by its header and meta tags.
+func (p DirectivePolicy) page(h http.Header, meta map[string]RobotsDirectives) RobotsDirectives {
+	d := HeaderRobotsDirectives(h, p.UserAgent).merge(meta["robots"])
+	if p.UserAgent != "" {
+		d = d.merge(meta[strings.ToLower(p.UserAgent)])
+	}
+	return RobotsDirectives{NoIndex: p.NoIndex && d.NoIndex, NoFollow: p.NoFollow && d.NoFollow}
+}
+
+// follows tells whether the link of a page with the directives may be fetched.
+func (p DirectivePolicy) follows(link Link, page RobotsDirectives) bool {
+	if page.NoFollow {
+		return false
+	}
+	if p.RelNofollow {
+		for _, rel := range strings.Fields(link.Rel) {
+			if strings.EqualFold(rel, "nofollow") {
+				return false
+			}
+		}
+	}
+	return true
+}
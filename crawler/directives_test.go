@@ -0,0 +1,146 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRobotsDirectives(t *testing.T) {
+	assert.Equal(t, RobotsDirectives{NoIndex: true, NoFollow: true}, ParseRobotsDirectives("NOINDEX , nofollow"))
+	assert.Equal(t, RobotsDirectives{NoIndex: true, NoFollow: true}, ParseRobotsDirectives("none"))
+	assert.Equal(t, RobotsDirectives{NoFollow: true}, ParseRobotsDirectives("index, nofollow, max-snippet:-1"))
+	assert.Equal(t, RobotsDirectives{}, ParseRobotsDirectives("all"))
+}
+
+func TestHeaderRobotsDirectives(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   RobotsDirectives
+	}{
+		{name: "для всех", values: []string{"noindex"}, want: RobotsDirectives{NoIndex: true}},
+		{name: "несколько заголовков", values: []string{"noindex", "nofollow"}, want: RobotsDirectives{NoIndex: true, NoFollow: true}},
+		{name: "для нашего робота", values: []string{"Crawler: nofollow"}, want: RobotsDirectives{NoFollow: true}},
+		{name: "для другого робота", values: []string{"googlebot: noindex, nofollow"}, want: RobotsDirectives{}},
+		{name: "директива с двоеточием", values: []string{"unavailable_after: 25 Jun 2010 15:00:00 PST, noindex"}, want: RobotsDirectives{NoIndex: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{"X-Robots-Tag": tt.values}
+			assert.Equal(t, tt.want, HeaderRobotsDirectives(h, "crawler"))
+		})
+	}
+}
+
+// pageRecorder is a Sink keeping the written pages by their paths.
+type pageRecorder struct {
+	mu    sync.Mutex
+	pages map[string]Page
+}
+
+func (r *pageRecorder) Write(page Page) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pages == nil {
+		r.pages = make(map[string]Page)
+	}
+	u, _ := url.Parse(page.URL.String())
+	page.Content = append([]byte(nil), page.Content...)
+	r.pages[u.Path] = page
+	return nil
+}
+
+func (r *pageRecorder) paths() []string {
+	paths := make([]string, 0, len(r.pages))
+	for path := range r.pages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// indexed returns the paths of the pages not marked noindex.
+func (r *pageRecorder) indexed() []string {
+	paths := make([]string, 0, len(r.pages))
+	for path, page := range r.pages {
+		if !page.Robots.NoIndex {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func Test_processor_walkDirectives(t *testing.T) {
+	pages := map[string]string{
+		"/":       `<a href="/a">a</a><a href="/b" rel="NoFollow">b</a><a href="/meta">meta</a><a href="/header">header</a>`,
+		"/a":      `page a`,
+		"/b":      `page b`,
+		"/meta":   `<meta name="robots" content="noindex"><meta name="crawler" content="nofollow"><a href="/c">c</a>`,
+		"/header": `<a href="/d">d</a>`,
+		"/c":      `page c`,
+		"/d":      `page d`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/header" {
+			w.Header().Add("X-Robots-Tag", "noindex, nofollow")
+		}
+		_, _ = fmt.Fprint(w, body)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		policy  DirectivePolicy
+		fetched int
+		indexed []string
+	}{
+		{
+			name:    "по умолчанию",
+			policy:  DefaultDirectivePolicy,
+			fetched: 5,
+			indexed: []string{"/", "/a", "/c"},
+		},
+		{
+			name:    "для нашего робота",
+			policy:  DirectivePolicy{RelNofollow: true, NoFollow: true, NoIndex: true, UserAgent: "Crawler"},
+			fetched: 4,
+			indexed: []string{"/", "/a"},
+		},
+		{
+			name:    "без директив",
+			policy:  DirectivePolicy{},
+			fetched: 7,
+			indexed: []string{"/", "/a", "/b", "/c", "/d", "/header", "/meta"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &pageRecorder{}
+			graph := NewLinkGraph()
+			w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}), 2, 100, MetricMock{}, nil)
+			p := New(w, MetricMock{}, WithDirectives(tt.policy), WithSinks(sink, graph), WithFollow(LinkAnchor))
+			got, err := p.Walk(context.Background(), []URL{URL(ts.URL + "/")})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.fetched, got.Fetched)
+
+			assert.Equal(t, tt.indexed, sink.indexed())
+			// страницы с noindex остаются в графе ссылок
+			assert.Contains(t, graph.Edges(), Edge{Source: URL(ts.URL + "/meta"), Target: URL(ts.URL + "/c"), Text: "c"})
+			assert.Contains(t, graph.Edges(), Edge{Source: URL(ts.URL + "/header"), Target: URL(ts.URL + "/d"), Text: "d"})
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid request")
+	ErrTimeout        = errors.New("request timeout")
+	ErrCanceled       = errors.New("request canceled")
+	ErrNetwork        = errors.New("network error")
+)
+
+// FetchError is a reason why a url was not fetched. Kind is one of
+// ErrInvalidRequest, ErrTimeout, ErrCanceled or ErrNetwork,
+// so errors.Is(err, ErrTimeout) tells a timeout from other failures.
+type FetchError struct {
+	Kind error
+	URL  URL
+	Err  error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("fetch %s: %v: %v", e.URL, e.Kind, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FetchError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// fetchError classifies an error of http.Client.Do.
+func fetchError(ctx context.Context, url URL, err error) *FetchError {
+	kind := ErrNetwork
+	var netErr net.Error
+	switch {
+	case ctx.Err() != nil:
+		kind = ErrCanceled
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		kind = ErrTimeout
+	}
+	return &FetchError{Kind: kind, URL: url, Err: err}
+}
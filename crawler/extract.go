@@ -0,0 +1,286 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// LinkKind is the element a link was found in.
+type LinkKind int
+
+const (
+	// LinkAnchor is <a href>.
+	LinkAnchor LinkKind = iota
+	// LinkArea is <area href> of an image map.
+	LinkArea
+	// LinkFrame is <iframe src> or <frame src>.
+	LinkFrame
+	// LinkRefresh is <meta http-equiv="refresh" content="0; url=...">.
+	LinkRefresh
+	// LinkHead is <link href>, e.g. a stylesheet or an alternate page.
+	LinkHead
+	// LinkImage is <img src> or a candidate of <img srcset>.
+	LinkImage
+	// LinkScript is <script src>.
+	LinkScript
+	// LinkForm is <form action>.
+	LinkForm
+	// LinkCSS is url() of a <style> element or a style attribute.
+	LinkCSS
+)
+
+var linkKindNames = []string{"a", "area", "iframe", "refresh", "link", "img", "script", "form", "css"}
+
+func (k LinkKind) String() string {
+	if k >= 0 && int(k) < len(linkKindNames) {
+		return linkKindNames[k]
+	}
+	return "kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// ParseLinkKind reads a kind by the name of its element: a, area, iframe,
+// refresh, link, img, script, form or css.
+func ParseLinkKind(s string) (LinkKind, error) {
+	for k, name := range linkKindNames {
+		if strings.EqualFold(s, name) {
+			return LinkKind(k), nil
+		}
+	}
+	return LinkAnchor, fmt.Errorf("unknown link kind %q", s)
+}
+
+// DefaultFollow are the kinds of links to other pages.
+var DefaultFollow = []LinkKind{LinkAnchor, LinkArea, LinkFrame, LinkRefresh}
+
+// Link is a link found on a page.
+type Link struct {
+	URL URL
+	// Text is the anchor text with collapsed spaces.
+	Text string
+	Rel  string
+	Kind LinkKind
+}
+
+// Extractor finds links of the chosen kinds on a page.
+type Extractor struct {
+	kinds map[LinkKind]bool
+}
+
+// NewExtractor extracts links of the kinds, of all kinds when none are given.
+func NewExtractor(kinds ...LinkKind) *Extractor {
+	e := &Extractor{kinds: make(map[LinkKind]bool)}
+	if len(kinds) == 0 {
+		for k := range linkKindNames {
+			e.kinds[LinkKind(k)] = true
+		}
+	}
+	for _, k := range kinds {
+		e.kinds[k] = true
+	}
+	return e
+}
+
+var (
+	defaultExtractor = NewExtractor()
+	followExtractor  = NewExtractor(DefaultFollow...)
+)
+
+// ExtractLinks returns absolute http(s) links of the DefaultFollow kinds of the page.
+// Relative links are resolved against the page url or its <base href>.
+func ExtractLinks(page URL, body io.ReadCloser) []string {
+	links := extractLinks(page, body)
+	urls := make([]string, len(links))
+	for i, link := range links {
+		urls[i] = link.URL.String()
+	}
+	return urls
+}
+
+func extractLinks(page URL, body io.ReadCloser) []Link {
+	return followExtractor.Extract(page, body)
+}
+
+// cssURL matches url(...) with an optionally quoted address.
+var cssURL = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+
+// Document is what the extractor found on a page.
+type Document struct {
+	// Links are in the order of the page.
+	Links []Link
+	// Robots are the directives of <meta name="robots"> and of meta tags
+	// named after a robot, by the lowercase name.
+	Robots map[string]RobotsDirectives
+}
+
+// Extract reads the page and closes the body. Links are returned in the
+// order of the document.
+func (e *Extractor) Extract(page URL, body io.ReadCloser) []Link {
+	return e.Parse(page, body).Links
+}
+
+// Parse reads the links and robots meta tags of the page and closes the body.
+func (e *Extractor) Parse(page URL, body io.ReadCloser) Document {
+	links := make([]Link, 0)
+	robots := make(map[string]RobotsDirectives)
+	if body == nil {
+		return Document{Links: links, Robots: robots}
+	}
+	defer body.Close()
+	base, err := url.Parse(page.String())
+	if err != nil || !base.IsAbs() {
+		base = nil
+	}
+	baseSet := false
+	anchor := -1
+	inStyle := false
+	var text strings.Builder
+	closeAnchor := func() {
+		if anchor >= 0 {
+			links[anchor].Text = strings.Join(strings.Fields(text.String()), " ")
+		}
+		anchor = -1
+		text.Reset()
+	}
+	add := func(kind LinkKind, ref string, rel string) bool {
+		if !e.kinds[kind] {
+			return false
+		}
+		u, ok := resolveLink(base, ref)
+		if !ok {
+			return false
+		}
+		links = append(links, Link{URL: URL(u.String()), Rel: strings.Join(strings.Fields(rel), " "), Kind: kind})
+		return true
+	}
+	addCSS := func(css string) {
+		for _, m := range cssURL.FindAllStringSubmatch(css, -1) {
+			add(LinkCSS, m[1]+m[2]+m[3], "")
+		}
+	}
+	tokenizer := html.NewTokenizer(body)
+	for {
+		tt := tokenizer.Next()
+		switch tt {
+		case html.ErrorToken:
+			// a read error ends the page, the links found so far are kept
+			closeAnchor()
+			return Document{Links: links, Robots: robots}
+		case html.TextToken:
+			if anchor >= 0 {
+				text.Write(tokenizer.Text())
+			}
+			if inStyle {
+				addCSS(string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			switch tag, _ := tokenizer.TagName(); string(tag) {
+			case "a":
+				closeAnchor()
+			case "style":
+				inStyle = false
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			tag := string(name)
+			attrs := make(map[string]string)
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				if _, ok := attrs[string(key)]; !ok {
+					attrs[string(key)] = string(value)
+				}
+			}
+			if style, ok := attrs["style"]; ok {
+				addCSS(style)
+			}
+			switch tag {
+			case "base":
+				if href, ok := resolveLink(base, attrs["href"]); ok && !baseSet {
+					base = href
+					baseSet = true
+				}
+			case "a":
+				closeAnchor()
+				if href, ok := attrs["href"]; ok && add(LinkAnchor, href, attrs["rel"]) && tt == html.StartTagToken {
+					anchor = len(links) - 1
+				}
+			case "area":
+				if href, ok := attrs["href"]; ok {
+					add(LinkArea, href, attrs["rel"])
+				}
+			case "link":
+				if href, ok := attrs["href"]; ok {
+					add(LinkHead, href, attrs["rel"])
+				}
+			case "iframe", "frame":
+				if src, ok := attrs["src"]; ok {
+					add(LinkFrame, src, "")
+				}
+			case "img":
+				if src, ok := attrs["src"]; ok {
+					add(LinkImage, src, "")
+				}
+				for _, candidate := range strings.Split(attrs["srcset"], ",") {
+					if fields := strings.Fields(candidate); len(fields) > 0 {
+						add(LinkImage, fields[0], "")
+					}
+				}
+			case "script":
+				if src, ok := attrs["src"]; ok {
+					add(LinkScript, src, "")
+				}
+			case "form":
+				if action, ok := attrs["action"]; ok {
+					add(LinkForm, action, "")
+				}
+			case "meta":
+				if strings.EqualFold(strings.TrimSpace(attrs["http-equiv"]), "refresh") {
+					if ref, ok := refreshURL(attrs["content"]); ok {
+						add(LinkRefresh, ref, "")
+					}
+				}
+				if d := ParseRobotsDirectives(attrs["content"]); d != (RobotsDirectives{}) {
+					name := strings.ToLower(strings.TrimSpace(attrs["name"]))
+					robots[name] = robots[name].merge(d)
+				}
+			case "style":
+				inStyle = tt == html.StartTagToken
+			}
+		}
+	}
+}
+
+// refreshURL reads the url of a meta refresh content, e.g. `5; url='/next'`.
+func refreshURL(content string) (string, bool) {
+	_, ref, ok := strings.Cut(content, ";")
+	if !ok {
+		return "", false
+	}
+	ref = strings.TrimSpace(ref)
+	if key, value, ok := strings.Cut(ref, "="); ok && strings.EqualFold(strings.TrimSpace(key), "url") {
+		ref = strings.TrimSpace(value)
+	}
+	ref = strings.Trim(ref, `"'`)
+	return ref, ref != ""
+}
+
+// resolveLink makes href absolute against base and keeps http(s) links only.
+func resolveLink(base *url.URL, href string) (*url.URL, bool) {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return nil, false
+	}
+	if base != nil {
+		ref = base.ResolveReference(ref)
+	}
+	if (ref.Scheme != "http" && ref.Scheme != "https") || ref.Host == "" {
+		return nil, false
+	}
+	return ref, true
+}
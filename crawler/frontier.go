@@ -0,0 +1,267 @@
+package crawler
+
+import (
+	"container/heap"
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Candidate is a task waiting in the frontier.
+type Candidate struct {
+	Task
+	// Seq is an order in which the url was discovered.
+	Seq int
+	// Inlinks is a number of links to the url found by the crawl so far.
+	Inlinks int
+}
+
+// Scorer gives a priority to a candidate, the highest one is fetched first.
+// Candidates with equal priority are fetched in the order of discovery.
+type Scorer func(c Candidate) float64
+
+// discovered keeps the order in which urls are found.
+func discovered(Candidate) float64 {
+	return 0
+}
+
+// BreadthFirst fetches pages of a lower depth first.
+func BreadthFirst(c Candidate) float64 {
+	return -float64(c.Depth)
+}
+
+// DepthFirst follows the most recently discovered link first.
+func DepthFirst(c Candidate) float64 {
+	return float64(c.Seq)
+}
+
+// MostLinked fetches pages with more in-links first.
+func MostLinked(c Candidate) float64 {
+	return float64(c.Inlinks)
+}
+
+// SitemapPriority fetches urls from sitemaps first, by their priority,
+// then applies next.
+func SitemapPriority(next Scorer) Scorer {
+	const lift = 1 << 52
+	return func(c Candidate) float64 {
+		score := 0.0
+		if next != nil {
+			score = next(c)
+		}
+		if c.Priority > 0 {
+			return score + lift*(1+c.Priority)
+		}
+		return score
+	}
+}
+
+// PreferHosts fetches pages of the hosts first, then applies next.
+func PreferHosts(next Scorer, hosts ...string) Scorer {
+	preferred := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		preferred[strings.ToLower(host)] = true
+	}
+	return prefer(next, func(c Candidate) bool {
+		return preferred[c.URL.Host()]
+	})
+}
+
+// PreferMatching fetches urls matching any of the patterns first, then applies next.
+func PreferMatching(next Scorer, patterns ...*regexp.Regexp) Scorer {
+	return prefer(next, func(c Candidate) bool {
+		for _, re := range patterns {
+			if re.MatchString(c.URL.String()) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// prefer lifts the matching candidates above any score of next.
+func prefer(next Scorer, match func(c Candidate) bool) Scorer {
+	const lift = 1 << 52
+	return func(c Candidate) float64 {
+		score := 0.0
+		if next != nil {
+			score = next(c)
+		}
+		if match(c) {
+			return score + lift
+		}
+		return score
+	}
+}
+
+type frontierItem struct {
+	Candidate
+	host  string
+	score float64
+	index int
+}
+
+// before tells whether the item is fetched before the other one.
+func (i *frontierItem) before(other *frontierItem) bool {
+	if i.score != other.score {
+		return i.score > other.score
+	}
+	return i.Seq < other.Seq
+}
+
+type frontierHeap []*frontierItem
+
+func (h frontierHeap) Len() int { return len(h) }
+
+func (h frontierHeap) Less(i, j int) bool {
+	return h[i].before(h[j])
+}
+
+func (h frontierHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+
+func (h *frontierHeap) Push(x interface{}) {
+	item := x.(*frontierItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *frontierHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return item
+}
+
+// frontier keeps tasks ordered by a Scorer until the worker takes them.
+// Tasks are kept in a heap per host, so a host that is not ready
+// doesn't hold up the others.
+type frontier struct {
+	score  Scorer
+	limit  int
+	mu     sync.Mutex
+	hosts  map[string]*frontierHeap
+	queued map[URL]*frontierItem
+	seq    int
+	wake   chan struct{}
+}
+
+// newFrontier keeps at most limit tasks, 0 is for no limit.
+func newFrontier(score Scorer, limit int) *frontier {
+	return &frontier{
+		score:  score,
+		limit:  limit,
+		hosts:  make(map[string]*frontierHeap),
+		queued: make(map[URL]*frontierItem),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// push queues the tasks, a task found on a page has one in-link.
+// It returns the number of tasks dropped as the frontier is full,
+// they are the last ones.
+func (f *frontier) push(tasks []Task) int {
+	if len(tasks) == 0 {
+		return 0
+	}
+	f.mu.Lock()
+	dropped := 0
+	if f.limit > 0 && len(f.queued)+len(tasks) > f.limit {
+		dropped = len(f.queued) + len(tasks) - f.limit
+		if dropped > len(tasks) {
+			dropped = len(tasks)
+		}
+		tasks = tasks[:len(tasks)-dropped]
+	}
+	for _, t := range tasks {
+		c := Candidate{Task: t, Seq: f.seq}
+		if t.Depth > 0 {
+			c.Inlinks = 1
+		}
+		f.seq++
+		item := &frontierItem{Candidate: c, host: t.URL.Host(), score: f.score(c)}
+		h, ok := f.hosts[item.host]
+		if !ok {
+			h = new(frontierHeap)
+			f.hosts[item.host] = h
+		}
+		heap.Push(h, item)
+		f.queued[t.URL] = item
+	}
+	f.mu.Unlock()
+	signal(f.wake)
+	return dropped
+}
+
+// link counts links to the urls that are still queued.
+func (f *frontier) link(urls []URL) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, u := range urls {
+		if item, ok := f.queued[u]; ok {
+			item.Inlinks++
+			item.score = f.score(item.Candidate)
+			heap.Fix(f.hosts[item.host], item.index)
+		}
+	}
+}
+
+// pop takes the best task of the hosts that are ready, of any host when ready is nil.
+func (f *frontier) pop(ready func(host string) bool) (Task, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var best *frontierItem
+	for host, h := range f.hosts {
+		if head := (*h)[0]; (best == nil || head.before(best)) && (ready == nil || ready(host)) {
+			best = head
+		}
+	}
+	if best == nil {
+		return Task{}, false
+	}
+	h := f.hosts[best.host]
+	heap.Pop(h)
+	if h.Len() == 0 {
+		delete(f.hosts, best.host)
+	}
+	delete(f.queued, best.URL)
+	return best.Task, true
+}
+
+func (f *frontier) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queued)
+}
+
+// dispatch submits tasks to the worker one by one, so the order is kept
+// while the worker is busy. A HostGate gets the next task of a host only
+// when it is ready for it. It returns when the walk is idle or cancelled.
+func (f *frontier) dispatch(ctx context.Context, worker Worker, idle <-chan struct{}, metrics Metrics) {
+	var ready func(host string) bool
+	var gateWake <-chan struct{}
+	if gate, ok := worker.(HostGate); ok {
+		ready, gateWake = gate.Ready, gate.Wake()
+	}
+	for {
+		if ctx.Err() != nil {
+			metrics.IncSkipped(f.len())
+			return
+		}
+		if t, ok := f.pop(ready); ok {
+			worker.SubmitTasks([]Task{t})
+			continue
+		}
+		select {
+		case <-f.wake:
+		case <-gateWake:
+		case <-idle:
+			return
+		case <-ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_frontier(t *testing.T) {
+	tasks := []Task{
+		{URL: "https://a.ru/", Depth: 0},
+		{URL: "https://a.ru/1", Depth: 1},
+		{URL: "https://b.ru/1", Depth: 1},
+		{URL: "https://a.ru/1/2", Depth: 2},
+		{URL: "https://b.ru/", Depth: 0},
+	}
+	tests := []struct {
+		name    string
+		score   Scorer
+		limit   int
+		links   []URL
+		want    []URL
+		dropped int
+	}{
+		{
+			name:  "в ширину",
+			score: BreadthFirst,
+			want:  []URL{"https://a.ru/", "https://b.ru/", "https://a.ru/1", "https://b.ru/1", "https://a.ru/1/2"},
+		},
+		{
+			name:  "в глубину",
+			score: DepthFirst,
+			want:  []URL{"https://b.ru/", "https://a.ru/1/2", "https://b.ru/1", "https://a.ru/1", "https://a.ru/"},
+		},
+		{
+			name:  "по числу входящих ссылок",
+			score: MostLinked,
+			links: []URL{"https://a.ru/1/2", "https://b.ru/1", "https://a.ru/1/2", "https://unknown.ru/"},
+			want:  []URL{"https://a.ru/1/2", "https://b.ru/1", "https://a.ru/1", "https://a.ru/", "https://b.ru/"},
+		},
+		{
+			name:  "сначала хост",
+			score: PreferHosts(BreadthFirst, "B.ru"),
+			want:  []URL{"https://b.ru/", "https://b.ru/1", "https://a.ru/", "https://a.ru/1", "https://a.ru/1/2"},
+		},
+		{
+			name:  "сначала по шаблону",
+			score: PreferMatching(nil, regexp.MustCompile(`/1$`)),
+			want:  []URL{"https://a.ru/1", "https://b.ru/1", "https://a.ru/", "https://a.ru/1/2", "https://b.ru/"},
+		},
+		{
+			name:    "в порядке обнаружения с лимитом",
+			score:   discovered,
+			limit:   3,
+			want:    []URL{"https://a.ru/", "https://a.ru/1", "https://b.ru/1"},
+			dropped: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFrontier(tt.score, tt.limit)
+			assert.Equal(t, tt.dropped, f.push(tasks))
+			f.link(tt.links)
+			got := make([]URL, 0, len(tasks))
+			for {
+				task, ok := f.pop(nil)
+				if !ok {
+					break
+				}
+				got = append(got, task.URL)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// stepWorker is also the Journal of the walk, it starts the next task
+// only when the page of the previous one is done. So the order of fetches
+// depends on the scorer alone.
+type stepWorker struct {
+	Worker
+	mu   sync.Mutex
+	busy bool
+	wake chan struct{}
+}
+
+func newStepWorker(fn WorkerFunc) *stepWorker {
+	return &stepWorker{Worker: NewWorkerV2(fn, 1, 0, MetricMock{}, nil), wake: make(chan struct{}, 1)}
+}
+
+func (w *stepWorker) SubmitTasks(tasks []Task) <-chan Result {
+	if len(tasks) > 0 {
+		w.mu.Lock()
+		w.busy = true
+		w.mu.Unlock()
+	}
+	return w.Worker.SubmitTasks(tasks)
+}
+
+func (w *stepWorker) Ready(string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !w.busy
+}
+
+func (w *stepWorker) Wake() <-chan struct{} { return w.wake }
+
+func (w *stepWorker) Restore() ([]URL, []Task) { return nil, nil }
+
+func (w *stepWorker) Seen([]URL) error { return nil }
+
+func (w *stepWorker) Queued([]Task) error { return nil }
+
+func (w *stepWorker) Done(URL) error {
+	w.mu.Lock()
+	w.busy = false
+	w.mu.Unlock()
+	signal(w.wake)
+	return nil
+}
+
+func Test_processor_walkPriority(t *testing.T) {
+	pages := map[string]string{
+		"/":  `<a href="/a">a</a><a href="/b">b</a><a href="/c">c</a>`,
+		"/a": `<a href="/x">x</a><a href="/y">y</a>`,
+		"/b": `<a href="/y">y</a>`,
+		"/c": `c`,
+		"/x": `x`,
+		"/y": `y`,
+	}
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name  string
+		score Scorer
+		want  []string
+	}{
+		{name: "в ширину", score: BreadthFirst, want: []string{"/", "/a", "/b", "/c", "/x", "/y"}},
+		{name: "в глубину", score: DepthFirst, want: []string{"/", "/c", "/b", "/y", "/a", "/x"}},
+		{name: "по числу ссылок", score: MostLinked, want: []string{"/", "/a", "/b", "/y", "/c", "/x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			requested = nil
+			mu.Unlock()
+			w := newStepWorker(WorkerHandler(http.Client{}, MetricMock{}))
+			p := New(w, MetricMock{}, WithPriority(tt.score), WithJournal(w))
+			got, err := p.Walk(context.Background(), []URL{URL(ts.URL + "/")})
+			assert.NoError(t, err)
+			assert.Equal(t, 6, got.Fetched)
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tt.want, requested)
+		})
+	}
+}
+
+func Test_processor_walkPriorityHosts(t *testing.T) {
+	began := time.Now()
+	var mu sync.Mutex
+	first := make(map[string]time.Duration)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if _, ok := first[r.Host]; !ok {
+			first[r.Host] = time.Since(began)
+		}
+		mu.Unlock()
+		_, _ = fmt.Fprint(w, "page")
+	}))
+	defer ts.Close()
+	// every host is served by the test server
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, ts.Listener.Addr().String())
+		},
+	}}
+
+	seeds := []URL{"http://a.test/1", "http://a.test/2", "http://a.test/3", "http://a.test/4", "http://b.test/", "http://c.test/"}
+	w := NewHostScheduler(WorkerHandler(client, MetricMock{}), HostPolicy{MaxConcurrent: 1, Delay: 200 * time.Millisecond},
+		func(fn WorkerFunc) Worker { return NewWorkerV2(fn, 4, 100, MetricMock{}, nil) })
+	p := New(w, MetricMock{}, WithPriority(BreadthFirst))
+	got, err := p.Walk(context.Background(), seeds)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, got.Fetched)
+
+	mu.Lock()
+	defer mu.Unlock()
+	// a.test is delayed between its pages, but the other hosts don't wait for it
+	assert.Less(t, first["b.test"], 200*time.Millisecond)
+	assert.Less(t, first["c.test"], 200*time.Millisecond)
+}
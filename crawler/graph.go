@@ -0,0 +1,189 @@
+package crawler
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Edge is a link from the Source page to the Target url.
+type Edge struct {
+	Source URL
+	Target URL
+	// Kind is the element of the link, only some kinds are followed.
+	Kind LinkKind
+	Text string
+	Rel  string
+}
+
+// linkGraph is a Sink recording links of the fetched pages, including
+// links to the urls that were not fetched.
+type linkGraph struct {
+	canonical []CanonicalRule
+	mu        sync.Mutex
+	edges     []Edge
+}
+
+// NewLinkGraph canonicalizes urls of the graph with the rules, they are
+// to be the canonical rules of the walk, so a page is a single node.
+func NewLinkGraph(rules ...CanonicalRule) *linkGraph {
+	return &linkGraph{canonical: rules}
+}
+
+func (g *linkGraph) Write(page Page) error {
+	source := page.FinalURL
+	if source == "" {
+		source = page.URL
+	}
+	source = g.node(source)
+	edges := make([]Edge, 0, len(page.Links))
+	for _, link := range page.Links {
+		edges = append(edges, Edge{Source: source, Target: g.node(link.URL), Kind: link.Kind, Text: link.Text, Rel: link.Rel})
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.edges = append(g.edges, edges...)
+	return nil
+}
+
+// node returns the canonical url, an invalid one is kept as it is.
+func (g *linkGraph) node(u URL) URL {
+	if c, err := u.Canonical(g.canonical...); err == nil {
+		return c
+	}
+	return u
+}
+
+// Edges returns the links in the order the pages were processed.
+func (g *linkGraph) Edges() []Edge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Edge(nil), g.edges...)
+}
+
+// nodes returns the urls of the graph in the order they appear.
+func (g *linkGraph) nodes(edges []Edge) []URL {
+	seen := make(map[URL]bool)
+	nodes := make([]URL, 0)
+	for _, e := range edges {
+		for _, u := range []URL{e.Source, e.Target} {
+			if !seen[u] {
+				seen[u] = true
+				nodes = append(nodes, u)
+			}
+		}
+	}
+	return nodes
+}
+
+// WriteCSV writes the edge list with a source,target,kind,text,rel header.
+func (g *linkGraph) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"source", "target", "kind", "text", "rel"})
+	for _, e := range g.Edges() {
+		_ = cw.Write([]string{e.Source.String(), e.Target.String(), e.Kind.String(), e.Text, e.Rel})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteDOT writes the graph in the Graphviz format, edges are labeled by the anchor text
+// and have the kind of the link.
+func (g *linkGraph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph crawl {\n")
+	for _, e := range g.Edges() {
+		fmt.Fprintf(&b, "\t%s -> %s", dotQuote(e.Source.String()), dotQuote(e.Target.String()))
+		attrs := []string{"kind=" + dotQuote(e.Kind.String())}
+		if e.Text != "" {
+			attrs = append(attrs, "label="+dotQuote(e.Text))
+		}
+		if e.Rel != "" {
+			attrs = append(attrs, "rel="+dotQuote(e.Rel))
+		}
+		fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+// WriteGraphML writes the graph with the url of a node and the kind, text and rel of an edge.
+func (g *linkGraph) WriteGraphML(w io.Writer) error {
+	edges := g.Edges()
+	doc := graphML{XMLNS: "http://graphml.graphdrawing.org/xmlns"}
+	doc.Keys = []graphMLKey{
+		{ID: "url", For: "node", Name: "url", Type: "string"},
+		{ID: "kind", For: "edge", Name: "kind", Type: "string"},
+		{ID: "text", For: "edge", Name: "text", Type: "string"},
+		{ID: "rel", For: "edge", Name: "rel", Type: "string"},
+	}
+	doc.Graph.EdgeDefault = "directed"
+	ids := make(map[URL]string)
+	for i, u := range g.nodes(edges) {
+		ids[u] = fmt.Sprintf("n%d", i)
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{ID: ids[u], Data: []graphMLData{{Key: "url", Value: u.String()}}})
+	}
+	for _, e := range edges {
+		edge := graphMLEdge{Source: ids[e.Source], Target: ids[e.Target], Data: []graphMLData{{Key: "kind", Value: e.Kind.String()}}}
+		if e.Text != "" {
+			edge.Data = append(edge.Data, graphMLData{Key: "text", Value: e.Text})
+		}
+		if e.Rel != "" {
+			edge.Data = append(edge.Data, graphMLData{Key: "rel", Value: e.Rel})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,87 @@
+package crawler
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestGraph() *linkGraph {
+	g := NewLinkGraph(StripTracking, StripTrailingSlash)
+	_ = g.Write(Page{
+		Result: Result{URL: "https://a.ru", FinalURL: "https://a.ru/"},
+		Links: []Link{
+			{URL: "https://a.ru/b/?utm_source=tg", Text: `Say "b"`},
+			{URL: "https://c.ru/", Text: "c & co", Rel: "nofollow"},
+			{URL: "https://A.ru/logo.png", Kind: LinkImage},
+		},
+	})
+	_ = g.Write(Page{Result: Result{URL: "https://a.ru/b/"}, Links: []Link{{URL: "https://a.ru/"}}})
+	return g
+}
+
+func Test_linkGraph_WriteCSV(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, newTestGraph().WriteCSV(&b))
+	assert.Equal(t, `source,target,kind,text,rel
+https://a.ru/,https://a.ru/b,a,"Say ""b""",
+https://a.ru/,https://c.ru/,a,c & co,nofollow
+https://a.ru/,https://a.ru/logo.png,img,,
+https://a.ru/b,https://a.ru/,a,,
+`, b.String())
+}
+
+func Test_linkGraph_WriteDOT(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, newTestGraph().WriteDOT(&b))
+	assert.Equal(t, `digraph crawl {
+	"https://a.ru/" -> "https://a.ru/b" [kind="a", label="Say \"b\""];
+	"https://a.ru/" -> "https://c.ru/" [kind="a", label="c & co", rel="nofollow"];
+	"https://a.ru/" -> "https://a.ru/logo.png" [kind="img"];
+	"https://a.ru/b" -> "https://a.ru/" [kind="a"];
+}
+`, b.String())
+}
+
+func Test_linkGraph_WriteGraphML(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, newTestGraph().WriteGraphML(&b))
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="url" for="node" attr.name="url" attr.type="string"></key>
+  <key id="kind" for="edge" attr.name="kind" attr.type="string"></key>
+  <key id="text" for="edge" attr.name="text" attr.type="string"></key>
+  <key id="rel" for="edge" attr.name="rel" attr.type="string"></key>
+  <graph edgedefault="directed">
+    <node id="n0">
+      <data key="url">https://a.ru/</data>
+    </node>
+    <node id="n1">
+      <data key="url">https://a.ru/b</data>
+    </node>
+    <node id="n2">
+      <data key="url">https://c.ru/</data>
+    </node>
+    <node id="n3">
+      <data key="url">https://a.ru/logo.png</data>
+    </node>
+    <edge source="n0" target="n1">
+      <data key="kind">a</data>
+      <data key="text">Say &#34;b&#34;</data>
+    </edge>
+    <edge source="n0" target="n2">
+      <data key="kind">a</data>
+      <data key="text">c &amp; co</data>
+      <data key="rel">nofollow</data>
+    </edge>
+    <edge source="n0" target="n3">
+      <data key="kind">img</data>
+    </edge>
+    <edge source="n1" target="n0">
+      <data key="kind">a</data>
+    </edge>
+  </graph>
+</graphml>
+`, b.String())
+}
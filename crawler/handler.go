@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"crawler/log"
 )
@@ -25,38 +28,100 @@ func (u URLs) hash() string {
 	return URL(fmt.Sprintf("%v", u)).hash()
 }
 
+// Task is a url submitted to a Worker with its depth from the seed.
+type Task struct {
+	URL   URL
+	Depth int
+	// Parent is the page the url was found on, it is empty for a seed.
+	Parent URL
+	// LastMod and Priority are set for a url from a sitemap.
+	LastMod  time.Time
+	Priority float64
+}
+
+func (u URLs) Tasks(depth int) []Task {
+	tasks := make([]Task, len(u))
+	for i, url := range u {
+		tasks[i] = Task{URL: url, Depth: depth}
+	}
+	return tasks
+}
+
+// LinkedFrom makes tasks of the urls found on the parent page.
+func (u URLs) LinkedFrom(parent URL, depth int) []Task {
+	tasks := u.Tasks(depth)
+	for i := range tasks {
+		tasks[i].Parent = parent
+	}
+	return tasks
+}
+
 type Result struct {
+	// URL is the requested url.
+	URL URL
+	// FinalURL is the url of the page after redirects.
+	FinalURL URL
+	// Redirects are the urls redirected from, starting with URL.
+	Redirects     []URL
+	Depth         int
+	Parent        URL
 	Status        string
 	StatusCode    int
+	Header        http.Header
 	Body          io.ReadCloser
 	ContentLength int64
+	// Proto is a protocol of the response, e.g. "HTTP/1.1".
+	Proto string
+	// Request is the request of the final response.
+	Request *http.Request
+	// Err is a *FetchError when there is no response.
+	Err     error
+	Timings Timings
+	// Skipped tells why the body was not read by the ContentPolicy,
+	// Body is nil then.
+	Skipped SkipReason
+	// Truncated is set when the body was cut to the max size.
+	Truncated bool
 } //http.Response
 
 func NewResult(r *http.Response) Result {
-	return Result{
+	result := Result{
 		Status:        r.Status,
 		StatusCode:    r.StatusCode,
+		Header:        r.Header,
 		Body:          r.Body,
 		ContentLength: r.ContentLength,
+		Proto:         r.Proto,
+		Request:       r.Request,
+	}
+	if r.Request == nil {
+		return result
+	}
+	result.FinalURL = URL(r.Request.URL.String())
+	result.URL = result.FinalURL
+	for req := r.Request; req.Response != nil && req.Response.Request != nil; req = req.Response.Request {
+		result.URL = URL(req.Response.Request.URL.String())
+		result.Redirects = append([]URL{result.URL}, result.Redirects...)
 	}
+	return result
 }
 
-var (
-	ResultOK     = Result{Status: "200 OK", StatusCode: 200, Body: http.NoBody}
-	ResultFAIL   = Result{Status: "500 Internal Server Error", StatusCode: 500, Body: http.NoBody}
-	ResultCANCEL = Result{}
-)
+// NewFailedResult is a result of a url that got no response.
+func NewFailedResult(url URL, err *FetchError) Result {
+	return Result{URL: url, Err: err}
+}
 
-func (r Result) content() (string, error) {
+// content reads the body of a successful response. A failed Close
+// is returned unless reading failed first.
+func (r Result) content() (_ string, err error) {
 	if r.StatusCode < 200 || r.StatusCode >= 300 || r.Body == nil {
 		return "", nil
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			panic(err)
+	defer func() {
+		if cerr := r.Body.Close(); cerr != nil && err == nil {
+			err = cerr
 		}
-	}(r.Body)
+	}()
 	var b bytes.Buffer
 	if _, err := io.Copy(&b, r.Body); err != nil {
 		return "", err
@@ -68,24 +133,140 @@ func (u URL) String() string {
 	return string(u)
 }
 
-func WorkerHandler(client http.Client, metrics Metrics) WorkerFunc {
-	logger := log.Adapter(log.Printer)
+func (u URL) Host() string {
+	parsed, err := url.Parse(string(u))
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Host)
+}
+
+type handlerConfig struct {
+	retry   RetryPolicy
+	logger  log.Logger
+	content ContentPolicy
+}
+
+type HandlerOption func(c *handlerConfig)
+
+// WithRetryPolicy makes WorkerHandler repeat failed requests. Without it
+// every url is requested once.
+func WithRetryPolicy(policy RetryPolicy) HandlerOption {
+	return func(c *handlerConfig) {
+		c.retry = policy
+	}
+}
+
+// WithLogger logs requests of WorkerHandler. Without it logs are discarded.
+func WithLogger(logger log.Logger) HandlerOption {
+	return func(c *handlerConfig) {
+		c.logger = logger
+	}
+}
+
+// userAgent sets the User-Agent of requests that don't have one.
+type userAgent struct {
+	next  http.RoundTripper
+	agent string
+}
+
+// UserAgentTransport sends agent as the User-Agent of pages, HEAD
+// requests and sitemaps fetched by a client with the transport.
+// A nil next is for http.DefaultTransport.
+func UserAgentTransport(next http.RoundTripper, agent string) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return userAgent{next: next, agent: agent}
+}
+
+func (t userAgent) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.agent == "" || req.Header.Get("User-Agent") != "" {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.agent)
+	return t.next.RoundTrip(req)
+}
+
+func WorkerHandler(client http.Client, metrics Metrics, opts ...HandlerOption) WorkerFunc {
+	cfg := handlerConfig{retry: RetryPolicy{MaxAttempts: 1}, logger: log.Nop()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	logger := cfg.logger
 	return func(ctx context.Context, url URL) Result {
-		if ctx.Err() != nil {
-			metrics.IncRequestTimeout()
-			return ResultCANCEL
+		began := time.Now()
+		t := new(tracer)
+		if cfg.content.needsHead(url) {
+			if result, skipped := cfg.content.head(ctx, client, url); skipped {
+				result.Timings = t.result(began)
+				logger.Debug("skipped", log.F("url", url), log.F("host", url.Host()), log.F("reason", result.Skipped))
+				return result
+			}
 		}
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
-		if err != nil {
-			logger.Log(fmt.Sprintf("request handler: %v", err))
-			return ResultFAIL
+		for attempt := 1; ; attempt++ {
+			if ctx.Err() != nil {
+				metrics.IncRequestTimeout()
+				return NewFailedResult(url, &FetchError{Kind: ErrCanceled, URL: url, Err: ctx.Err()})
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
+			if err != nil {
+				logger.Warn("invalid request", log.F("url", url), log.F("err", err))
+				return NewFailedResult(url, &FetchError{Kind: ErrInvalidRequest, URL: url, Err: err})
+			}
+			metrics.AddInFlight(1)
+			r, err := client.Do(t.trace(req))
+			metrics.AddInFlight(-1)
+			if attempt < cfg.retry.MaxAttempts && ctx.Err() == nil && cfg.retry.retryable(r, err) {
+				if delay, ok := cfg.retry.delay(attempt, r); ok {
+					if err == nil {
+						discard(r.Body)
+					}
+					metrics.IncRetry()
+					logger.Debug("retry", log.F("url", url), log.F("attempt", attempt), log.F("delay", delay))
+					sleep(ctx, delay)
+					continue
+				}
+			}
+			if err != nil {
+				metrics.IncRequestTimeout()
+				result := NewFailedResult(url, fetchError(ctx, url, err))
+				logger.Debug("request failed", log.F("url", url), log.F("host", url.Host()), log.F("err", result.Err))
+				result.Timings = t.result(began)
+				return result
+			}
+			result := NewResult(r)
+			result.URL = url
+			if err := cfg.content.limit(&result); err != nil {
+				metrics.IncRequestTimeout()
+				result = NewFailedResult(url, fetchError(ctx, url, err))
+				logger.Debug("request failed", log.F("url", url), log.F("host", url.Host()), log.F("err", result.Err))
+				result.Timings = t.result(began)
+				return result
+			}
+			result.Timings = t.result(began)
+			if result.Skipped != "" {
+				logger.Debug("skipped", log.F("url", url), log.F("host", url.Host()), log.F("status", result.StatusCode), log.F("reason", result.Skipped))
+				return result
+			}
+			logger.Debug("fetched", log.F("url", url), log.F("host", url.Host()), log.F("status", result.StatusCode), log.F("took", result.Timings.Total))
+			return result
 		}
-		r, err := client.Do(req)
-		if err != nil {
-			//logger.Log(fmt.Sprintf("crawler handler: %v", err))
-			metrics.IncRequestTimeout()
-			return ResultFAIL
-		}
-		return NewResult(r)
+	}
+}
+
+// discard reads a bit of the body so the connection can be reused and closes it.
+func discard(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	_ = body.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
 	}
 }
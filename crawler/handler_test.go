@@ -3,9 +3,13 @@ package crawler
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -25,6 +29,15 @@ func NewContent(s string) *mockContent {
 	return &mockContent{Buffer: bytes.NewBufferString(s)}
 }
 
+// closeFailure is a body that fails to close.
+type closeFailure struct {
+	io.Reader
+}
+
+func (closeFailure) Close() error {
+	return errors.New("close failed")
+}
+
 func TestResult_content(t *testing.T) {
 
 	tests := []struct {
@@ -60,6 +73,15 @@ func TestResult_content(t *testing.T) {
 			want:    "content",
 			wantErr: assert.NoError,
 		},
+		{
+			name: "ошибка закрытия тела",
+			r: Result{
+				Body:       closeFailure{Reader: strings.NewReader("content")},
+				StatusCode: 200,
+			},
+			want:    "content",
+			wantErr: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -81,15 +103,22 @@ func Test_httpClient_Request(t *testing.T) {
 			w.WriteHeader(http.StatusGatewayTimeout)
 		case "5xx":
 			w.WriteHeader(http.StatusInternalServerError)
+		case "redirect":
+			http.Redirect(w, r, "/?p=moved", http.StatusFound)
+		case "moved":
+			http.Redirect(w, r, "/?p=final", http.StatusMovedPermanently)
 		default:
 			//w.Write(body)
+			w.Header().Set("X-Test", param)
 			w.WriteHeader(http.StatusOK)
 		}
 		return
 	}
 	ts := httptest.NewServer(http.HandlerFunc(testDummy))
+	defer ts.Close()
 
-	canceledCtx, _ := context.WithDeadline(context.Background(), time.Now())
+	canceledCtx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
 	type fields struct {
 		client http.Client
 	}
@@ -98,11 +127,13 @@ func Test_httpClient_Request(t *testing.T) {
 		url URL
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    Result
-		wantErr assert.ErrorAssertionFunc
+		name          string
+		fields        fields
+		args          args
+		wantStatus    int
+		wantFinalURL  URL
+		wantRedirects []URL
+		wantErr       error
 	}{
 		{
 			name: "timeout request",
@@ -113,8 +144,7 @@ func Test_httpClient_Request(t *testing.T) {
 				ctx: context.Background(),
 				url: URL(fmt.Sprintf("%s?p=%s", ts.URL, "timeout")),
 			},
-			want:    ResultFAIL,
-			wantErr: assert.Error,
+			wantErr: ErrTimeout,
 		},
 		{
 			name: "success request",
@@ -125,8 +155,8 @@ func Test_httpClient_Request(t *testing.T) {
 				ctx: context.Background(),
 				url: URL(ts.URL),
 			},
-			want:    ResultOK,
-			wantErr: assert.NoError,
+			wantStatus:   200,
+			wantFinalURL: URL(ts.URL),
 		},
 		{
 			name: "5xx error request",
@@ -137,8 +167,32 @@ func Test_httpClient_Request(t *testing.T) {
 				ctx: context.Background(),
 				url: URL(fmt.Sprintf("%s?p=%s", ts.URL, "5xx")),
 			},
-			want:    ResultFAIL,
-			wantErr: assert.NoError,
+			wantStatus:   500,
+			wantFinalURL: URL(ts.URL + "?p=5xx"),
+		},
+		{
+			name: "redirect request",
+			fields: fields{
+				http.Client{},
+			},
+			args: args{
+				ctx: context.Background(),
+				url: URL(fmt.Sprintf("%s?p=%s", ts.URL, "redirect")),
+			},
+			wantStatus:    200,
+			wantFinalURL:  URL(ts.URL + "/?p=final"),
+			wantRedirects: []URL{URL(ts.URL + "?p=redirect"), URL(ts.URL + "/?p=moved")},
+		},
+		{
+			name: "invalid request",
+			fields: fields{
+				http.Client{},
+			},
+			args: args{
+				ctx: context.Background(),
+				url: "http://[::1",
+			},
+			wantErr: ErrInvalidRequest,
 		},
 		{
 			name: "cancel request",
@@ -149,8 +203,7 @@ func Test_httpClient_Request(t *testing.T) {
 				ctx: canceledCtx,
 				url: URL(fmt.Sprintf("%s?p=%s", ts.URL, "5xx")),
 			},
-			want:    ResultCANCEL,
-			wantErr: assert.NoError,
+			wantErr: ErrCanceled,
 		},
 	}
 
@@ -158,7 +211,87 @@ func Test_httpClient_Request(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			handler := WorkerHandler(tt.fields.client, MetricMock{})
 			got := handler(tt.args.ctx, tt.args.url)
-			assert.Equalf(t, tt.want, got, "handler(%v, %v)", tt.args.ctx, tt.args.url)
+			assert.Equal(t, tt.args.url, got.URL, "requested url")
+			assert.Equal(t, tt.wantStatus, got.StatusCode, "status code")
+			assert.Equal(t, tt.wantFinalURL, got.FinalURL, "final url")
+			assert.Equal(t, tt.wantRedirects, got.Redirects, "redirects")
+			if tt.wantErr == nil {
+				assert.NoError(t, got.Err)
+				assert.NotNil(t, got.Header, "headers")
+				assert.Greater(t, got.Timings.TTFB, time.Duration(0), "time to first byte")
+				assert.GreaterOrEqual(t, got.Timings.Total, got.Timings.TTFB, "total time")
+				return
+			}
+			assert.ErrorIs(t, got.Err, tt.wantErr)
+			var fetchErr *FetchError
+			assert.ErrorAs(t, got.Err, &fetchErr)
+		})
+	}
+}
+
+func TestUserAgentTransport(t *testing.T) {
+	var mu sync.Mutex
+	agents := make(map[string]string)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents[r.Method+" "+r.URL.Path] = r.UserAgent()
+		mu.Unlock()
+		if r.URL.Path == "/sitemap.xml" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	client := http.Client{Transport: UserAgentTransport(nil, "testbot/1.0")}
+
+	tests := []struct {
+		name    string
+		request func()
+		key     string
+		want    string
+	}{
+		{
+			name: "HEAD",
+			request: func() {
+				WorkerHandler(client, MetricMock{}, WithContentPolicy(ContentPolicy{Types: []string{"text/html"}, HeadFirst: true}))(context.Background(), URL(ts.URL+"/data.bin"))
+			},
+			key:  "HEAD /data.bin",
+			want: "testbot/1.0",
+		},
+		{
+			name: "GET страницы",
+			request: func() {
+				WorkerHandler(client, MetricMock{})(context.Background(), URL(ts.URL+"/page"))
+			},
+			key:  "GET /page",
+			want: "testbot/1.0",
+		},
+		{
+			name: "карта сайта",
+			request: func() {
+				NewSitemaps(client, nil, 0).URLs(context.Background(), URL(ts.URL+"/"))
+			},
+			key:  "GET /sitemap.xml",
+			want: "testbot/1.0",
+		},
+		{
+			name: "свой User-Agent запроса",
+			request: func() {
+				req, _ := http.NewRequest(http.MethodGet, ts.URL+"/own", nil)
+				req.Header.Set("User-Agent", "own")
+				if resp, err := client.Do(req); err == nil {
+					_ = resp.Body.Close()
+				}
+			},
+			key:  "GET /own",
+			want: "own",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.request()
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tt.want, agents[tt.key])
 		})
 	}
 }
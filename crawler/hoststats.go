@@ -0,0 +1,168 @@
+package crawler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// BreakerPolicy pauses a host that keeps failing. A paused host gets
+// a single probe request after Cooldown, the host is resumed when
+// the probe succeeds, otherwise the cooldown doubles up to MaxCooldown.
+type BreakerPolicy struct {
+	// Failures is a number of consecutive failed requests that pauses a host,
+	// zero disables the breaker.
+	Failures    int
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+}
+
+// HostStats describes requests to a host.
+type HostStats struct {
+	Host       string
+	Requests   int
+	Errors     int
+	P50        time.Duration
+	P95        time.Duration
+	Bytes      int64
+	LastStatus int
+	// Paused is true while the breaker keeps requests from the host.
+	Paused bool
+}
+
+func (s HostStats) ErrorRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Requests)
+}
+
+// latencyWindow is a number of the last requests percentiles are computed on.
+const latencyWindow = 512
+
+type hostStats struct {
+	requests   int
+	errors     int
+	latencies  []time.Duration
+	next       int
+	bytes      int64
+	lastStatus int
+
+	consecutive int
+	paused      bool
+	probing     bool
+	cooldown    time.Duration
+	resume      time.Time
+}
+
+// failed is true for a request without response, 5xx and 429.
+func failed(r Result) bool {
+	return r.Err != nil || r.StatusCode >= 500 || r.StatusCode == http.StatusTooManyRequests
+}
+
+// record updates stats and the breaker of the host with the result.
+// A cancelled request tells nothing about the host and is skipped.
+func (s *hostScheduler) record(host string, r Result, now time.Time) Result {
+	if errors.Is(r.Err, ErrCanceled) {
+		return r
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stats == nil {
+		s.stats = make(map[string]*hostStats)
+	}
+	st, ok := s.stats[host]
+	if !ok {
+		st = &hostStats{}
+		s.stats[host] = st
+	}
+	st.requests++
+	st.lastStatus = r.StatusCode
+	if len(st.latencies) < latencyWindow {
+		st.latencies = append(st.latencies, r.Timings.Total)
+	} else {
+		st.latencies[st.next] = r.Timings.Total
+		st.next = (st.next + 1) % latencyWindow
+	}
+	if r.Body != nil {
+		r.Body = countingReader{ReadCloser: r.Body, n: &st.bytes}
+	}
+
+	breaker := s.policy.Breaker
+	if !failed(r) {
+		st.consecutive = 0
+		st.paused, st.probing = false, false
+		return r
+	}
+	st.errors++
+	st.consecutive++
+	switch {
+	case st.probing:
+		st.probing = false
+		st.cooldown *= 2
+		if breaker.MaxCooldown > 0 && st.cooldown > breaker.MaxCooldown {
+			st.cooldown = breaker.MaxCooldown
+		}
+		st.resume = now.Add(st.cooldown)
+	case !st.paused && breaker.Failures > 0 && st.consecutive >= breaker.Failures:
+		st.paused = true
+		st.cooldown = breaker.Cooldown
+		st.resume = now.Add(st.cooldown)
+	}
+	return r
+}
+
+// paused tells ready whether the host can't get a request now and how long
+// to wait for a probe. It starts probing when the cooldown is over.
+func (s *hostScheduler) paused(h *hostQueue, now time.Time) (bool, time.Duration) {
+	st, ok := s.stats[h.name]
+	if !ok || !st.paused {
+		return false, 0
+	}
+	if d := st.resume.Sub(now); d > 0 {
+		return true, d
+	}
+	if st.probing || h.active > 0 {
+		return true, 0
+	}
+	st.probing = true
+	return false, 0
+}
+
+// Stats returns stats of every requested host ordered by name.
+func (s *hostScheduler) Stats() []HostStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]HostStats, 0, len(s.stats))
+	for host, st := range s.stats {
+		latencies := append([]time.Duration(nil), st.latencies...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		stats = append(stats, HostStats{
+			Host:       host,
+			Requests:   st.requests,
+			Errors:     st.errors,
+			P50:        percentile(latencies, 0.5),
+			P95:        percentile(latencies, 0.95),
+			Bytes:      atomic.LoadInt64(&st.bytes),
+			LastStatus: st.lastStatus,
+			Paused:     st.paused,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Host < stats[j].Host })
+	return stats
+}
+
+// percentile of sorted values by the nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,72 @@
+package crawler
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_HostSchedulerBreaker(t *testing.T) {
+	// release delays hosts from the real time, so the test runs ahead of it
+	now := time.Now().Add(time.Hour)
+	s := &hostScheduler{
+		policy: HostPolicy{MaxConcurrent: 1, Breaker: BreakerPolicy{Failures: 2, Cooldown: time.Second, MaxCooldown: 3 * time.Second}},
+		hosts:  make(map[string]*hostQueue),
+	}
+	s.enqueue(URLs{"https://a.com/1", "https://a.com/2", "https://a.com/3", "https://a.com/4", "https://a.com/5"}.Tasks(0))
+	s.enqueue(URLs{"https://b.com/1"}.Tasks(0))
+	fetch := func(at time.Time, r Result) {
+		s.record("a.com", r, at)
+		s.release("a.com")
+	}
+
+	tasks, _ := s.ready(now)
+	assert.Equal(t, URLs{"https://a.com/1", "https://b.com/1"}.Tasks(0), tasks)
+	fetch(now, resultFAIL)
+	tasks, _ = s.ready(now)
+	assert.Equal(t, URLs{"https://a.com/2"}.Tasks(0), tasks, "one failure doesn't pause a host")
+	fetch(now, resultFAIL)
+
+	tasks, wait := s.ready(now)
+	assert.Empty(t, tasks, "host is paused")
+	assert.Equal(t, time.Second, wait)
+
+	tasks, _ = s.ready(now.Add(time.Second))
+	assert.Equal(t, URLs{"https://a.com/3"}.Tasks(0), tasks, "probe after cooldown")
+	tasks, _ = s.ready(now.Add(time.Second))
+	assert.Empty(t, tasks, "one probe at a time")
+	fetch(now.Add(time.Second), NewFailedResult("https://a.com/3", &FetchError{Kind: ErrTimeout}))
+
+	tasks, wait = s.ready(now.Add(2 * time.Second))
+	assert.Empty(t, tasks)
+	assert.Equal(t, time.Second, wait, "cooldown doubles after a failed probe")
+	assert.True(t, s.Stats()[0].Paused)
+
+	tasks, _ = s.ready(now.Add(3 * time.Second))
+	assert.Equal(t, URLs{"https://a.com/4"}.Tasks(0), tasks)
+	fetch(now.Add(3*time.Second), resultOK)
+	tasks, _ = s.ready(now.Add(3 * time.Second))
+	assert.Equal(t, URLs{"https://a.com/5"}.Tasks(0), tasks, "host is resumed after a successful probe")
+
+	fetch(now, resultCANCEL)
+	stats := s.Stats()
+	assert.Equal(t, []HostStats{{Host: "a.com", Requests: 4, Errors: 3, LastStatus: 200}}, stats, "cancelled requests are not counted")
+	assert.Equal(t, 0.75, stats[0].ErrorRate())
+}
+
+func Test_HostSchedulerStats(t *testing.T) {
+	s := &hostScheduler{hosts: make(map[string]*hostQueue)}
+	for i := 1; i <= 20; i++ {
+		r := Result{StatusCode: 200, Body: io.NopCloser(strings.NewReader("body")), Timings: Timings{Total: time.Duration(i) * time.Millisecond}}
+		r = s.record("b.com", r, time.Now())
+		_, _ = io.ReadAll(r.Body)
+	}
+	s.record("a.com", Result{StatusCode: 404}, time.Now())
+	assert.Equal(t, []HostStats{
+		{Host: "a.com", Requests: 1, LastStatus: 404},
+		{Host: "b.com", Requests: 20, P50: 10 * time.Millisecond, P95: 19 * time.Millisecond, Bytes: 80, LastStatus: 200},
+	}, s.Stats())
+}
@@ -0,0 +1,199 @@
+package crawler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Records of the journal, one per line:
+//
+//	S <url>                     the url passed the duplicate check
+//	Q <depth> <url> [<parent>]  the url is submitted to the worker
+//	D <url>                     the result of the url is processed
+//
+// A url that is queued but not done was in flight when the crawl stopped.
+const (
+	journalSeen   = "S"
+	journalQueued = "Q"
+	journalDone   = "D"
+)
+
+// journal is an append-only log of the crawl frontier stored as <dir>/<job>.log.
+type journal struct {
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	seen    []URL
+	pending []Task
+}
+
+// OpenJournal opens the log of the job, replays it and compacts it
+// to the visited set and the tasks that were not done.
+func OpenJournal(dir, job string) (*journal, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, job+".log")
+	seen, pending, err := replayJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := compactJournal(path, seen, pending); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &journal{file: f, w: bufio.NewWriter(f), seen: seen, pending: pending}, nil
+}
+
+func replayJournal(path string) ([]URL, []Task, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	seen := make([]URL, 0)
+	queued := make(map[URL]Task)
+	order := make([]URL, 0)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 2 && fields[0] == journalSeen:
+			seen = append(seen, URL(fields[1]))
+		case (len(fields) == 3 || len(fields) == 4) && fields[0] == journalQueued:
+			depth, err := strconv.Atoi(fields[1])
+			if err != nil {
+				continue
+			}
+			t := Task{URL: URL(fields[2]), Depth: depth}
+			if len(fields) == 4 {
+				t.Parent = URL(fields[3])
+			}
+			if _, ok := queued[t.URL]; !ok {
+				order = append(order, t.URL)
+			}
+			queued[t.URL] = t
+		case len(fields) == 2 && fields[0] == journalDone:
+			delete(queued, URL(fields[1]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	pending := make([]Task, 0, len(queued))
+	for _, u := range order {
+		if t, ok := queued[u]; ok {
+			pending = append(pending, t)
+			delete(queued, u)
+		}
+	}
+	return seen, pending, nil
+}
+
+func compactJournal(path string, seen []URL, pending []Task) error {
+	if len(seen) == 0 && len(pending) == 0 {
+		return nil
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, u := range seen {
+		writeJournalRecord(w, journalSeen, u.String())
+	}
+	for _, t := range pending {
+		writeJournalQueued(w, t)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func writeJournalRecord(w *bufio.Writer, fields ...string) {
+	_, _ = w.WriteString(strings.Join(fields, " "))
+	_ = w.WriteByte('\n')
+}
+
+func writeJournalQueued(w *bufio.Writer, t Task) {
+	if t.Parent == "" {
+		writeJournalRecord(w, journalQueued, strconv.Itoa(t.Depth), t.URL.String())
+		return
+	}
+	writeJournalRecord(w, journalQueued, strconv.Itoa(t.Depth), t.URL.String(), t.Parent.String())
+}
+
+// Restore returns the visited set and the tasks to resume.
+func (j *journal) Restore() ([]URL, []Task) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seen, j.pending
+}
+
+func (j *journal) Seen(urls []URL) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, u := range urls {
+		writeJournalRecord(j.w, journalSeen, u.String())
+	}
+	return j.w.Flush()
+}
+
+func (j *journal) Queued(tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, t := range tasks {
+		writeJournalQueued(j.w, t)
+	}
+	return j.w.Flush()
+}
+
+func (j *journal) Done(u URL) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	writeJournalRecord(j.w, journalDone, u.String())
+	return j.w.Flush()
+}
+
+func (j *journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.w.Flush(); err != nil {
+		j.file.Close()
+		return fmt.Errorf("flush journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		j.file.Close()
+		return err
+	}
+	return j.file.Close()
+}
@@ -0,0 +1,78 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenJournal(t *testing.T) {
+	dir := t.TempDir()
+	j, err := OpenJournal(dir, "job")
+	if !assert.NoError(t, err) {
+		return
+	}
+	seen, pending := j.Restore()
+	assert.Empty(t, seen)
+	assert.Empty(t, pending)
+
+	assert.NoError(t, j.Seen([]URL{"https://a.ru/", "https://a.ru/x", "https://a.ru/y"}))
+	assert.NoError(t, j.Queued([]Task{{URL: "https://a.ru/", Depth: 0}}))
+	assert.NoError(t, j.Queued([]Task{{URL: "https://a.ru/x", Depth: 1, Parent: "https://a.ru/"}, {URL: "https://a.ru/y", Depth: 1, Parent: "https://a.ru/"}}))
+	assert.NoError(t, j.Done("https://a.ru/"))
+	assert.NoError(t, j.Done("https://a.ru/y"))
+	assert.NoError(t, j.Close())
+
+	j, err = OpenJournal(dir, "job")
+	assert.NoError(t, err)
+	seen, pending = j.Restore()
+	assert.Equal(t, []URL{"https://a.ru/", "https://a.ru/x", "https://a.ru/y"}, seen)
+	assert.Equal(t, []Task{{URL: "https://a.ru/x", Depth: 1, Parent: "https://a.ru/"}}, pending)
+	assert.NoError(t, j.Close())
+
+	j, err = OpenJournal(dir, "other")
+	assert.NoError(t, err)
+	seen, _ = j.Restore()
+	assert.Empty(t, seen, "jobs have separate journals")
+	assert.NoError(t, j.Close())
+}
+
+func Test_processor_walkResume(t *testing.T) {
+	ts := newSiteServer(map[string]string{
+		"/":  `<a href="/a">a</a><a href="/b">b</a>`,
+		"/a": `<a href="/b">b</a><a href="c">c</a>`,
+		"/b": `<a href="/">root</a>`,
+		"/c": `<a href="/a">a</a>`,
+	}, 0)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	j, err := OpenJournal(dir, "site")
+	assert.NoError(t, err)
+	root := URL(ts.URL + "/")
+	// the crawl stopped after the root page with its links queued
+	assert.NoError(t, j.Seen([]URL{root, root + "a", root + "b"}))
+	assert.NoError(t, j.Queued([]Task{{URL: root, Depth: 0}}))
+	assert.NoError(t, j.Queued([]Task{{URL: root + "a", Depth: 1}, {URL: root + "b", Depth: 1}}))
+	assert.NoError(t, j.Done(root))
+	assert.NoError(t, j.Close())
+
+	walk := func() Summary {
+		j, err := OpenJournal(dir, "site")
+		assert.NoError(t, err)
+		defer j.Close()
+		w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}), 2, 100, MetricMock{}, nil)
+		p := New(w, MetricMock{}, WithJournal(j))
+		got, err := p.Walk(context.Background(), []URL{URL(ts.URL)})
+		assert.NoError(t, err)
+		got.Duration, got.BloomFPRate, got.Bytes = 0, 0, 0
+		return got
+	}
+
+	// the root page isn't fetched again, the seed is a duplicate
+	assert.Equal(t, Summary{Fetched: 3, Failures: map[FailureClass]int{}, Duplicates: 4}, walk())
+	// nothing is left to do in a finished job
+	assert.Equal(t, Summary{Failures: map[FailureClass]int{}, Duplicates: 1}, walk())
+}
@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// jsonlRecord is a line of the crawl log. Durations are in milliseconds.
+type jsonlRecord struct {
+	URL         URL          `json:"url"`
+	FinalURL    URL          `json:"final_url,omitempty"`
+	Status      int          `json:"status"`
+	ContentType string       `json:"content_type,omitempty"`
+	Length      int          `json:"length"`
+	Depth       int          `json:"depth"`
+	Parent      URL          `json:"parent,omitempty"`
+	Timings     jsonlTimings `json:"timings"`
+	Error       string       `json:"error,omitempty"`
+	Skipped     SkipReason   `json:"skipped,omitempty"`
+	Truncated   bool         `json:"truncated,omitempty"`
+	NoIndex     bool         `json:"noindex,omitempty"`
+	Outlinks    int          `json:"outlinks"`
+	// Annotations are set by the handlers of the pipeline.
+	Annotations map[string]interface{} `json:"annotations,omitempty"`
+}
+
+type jsonlTimings struct {
+	DNS     float64 `json:"dns_ms"`
+	Connect float64 `json:"connect_ms"`
+	TLS     float64 `json:"tls_ms"`
+	TTFB    float64 `json:"ttfb_ms"`
+	Total   float64 `json:"total_ms"`
+}
+
+// jsonlWriter is a Sink writing a JSON line per page, failed pages included.
+type jsonlWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONLWriter(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{enc: json.NewEncoder(w)}
+}
+
+func (w *jsonlWriter) Write(page Page) error {
+	record := jsonlRecord{
+		URL:      page.URL,
+		FinalURL: page.FinalURL,
+		Status:   page.StatusCode,
+		Length:   len(page.Content),
+		Depth:    page.Depth,
+		Parent:   page.Parent,
+		Timings: jsonlTimings{
+			DNS:     milliseconds(page.Timings.DNS),
+			Connect: milliseconds(page.Timings.Connect),
+			TLS:     milliseconds(page.Timings.TLS),
+			TTFB:    milliseconds(page.Timings.TTFB),
+			Total:   milliseconds(page.Timings.Total),
+		},
+		Skipped:     page.Skipped,
+		Truncated:   page.Truncated,
+		NoIndex:     page.Robots.NoIndex,
+		Outlinks:    len(page.Links),
+		Annotations: page.Annotations,
+	}
+	if page.Header != nil {
+		record.ContentType = page.Header.Get("Content-Type")
+	}
+	if page.Err != nil {
+		record.Error = page.Err.Error()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(record)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,65 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLWriter(t *testing.T) {
+	ts := newSiteServer(map[string]string{
+		"/":  `<a href="/a">a</a><a href="/missing">?</a>`,
+		"/a": `<a href="/">root</a>`,
+	}, 0)
+	defer ts.Close()
+
+	var out bytes.Buffer
+	w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}), 2, 100, MetricMock{}, nil)
+	p := New(w, MetricMock{}, WithSinks(NewJSONLWriter(&out)))
+	_, err := p.Walk(context.Background(), []URL{URL(ts.URL), "http://127.0.0.1:1/"})
+	assert.NoError(t, err)
+
+	lines := make(map[URL]map[string]interface{})
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		if assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line), scanner.Text()) {
+			lines[URL(line["url"].(string))] = line
+		}
+	}
+	if !assert.Len(t, lines, 4) {
+		return
+	}
+
+	root := lines[URL(ts.URL+"/")]
+	assert.Equal(t, ts.URL+"/", root["final_url"])
+	assert.EqualValues(t, 200, root["status"])
+	assert.Equal(t, "text/html; charset=utf-8", root["content_type"])
+	assert.EqualValues(t, len(`<a href="/a">a</a><a href="/missing">?</a>`), root["length"])
+	assert.EqualValues(t, 0, root["depth"])
+	assert.Nil(t, root["parent"])
+	assert.EqualValues(t, 2, root["outlinks"])
+	assert.Contains(t, root["timings"], "total_ms")
+
+	a := lines[URL(ts.URL+"/a")]
+	assert.EqualValues(t, 1, a["depth"])
+	assert.Equal(t, ts.URL+"/", a["parent"])
+	assert.EqualValues(t, 1, a["outlinks"])
+
+	missing := lines[URL(ts.URL+"/missing")]
+	assert.EqualValues(t, 404, missing["status"])
+
+	failed := lines["http://127.0.0.1:1/"]
+	assert.EqualValues(t, 0, failed["status"])
+	assert.Contains(t, failed["error"], "network error")
+}
+
+func Test_milliseconds(t *testing.T) {
+	assert.Equal(t, 1.5, milliseconds(1500*time.Microsecond))
+}
@@ -1,7 +1,7 @@
 package crawler
 
 import (
-	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,20 +14,39 @@ type metrics struct {
 	submit    uint64
 	rtimeout  uint64
 	duplicate uint64
+	disallow  uint64
+	deep      uint64
+	outScope  uint64
+	retry     uint64
+	nofollow  uint64
+	noindex   uint64
+	queued    int64
+	inFlight  int64
+	mu        sync.Mutex
+	latency   map[resultLabels]*histogram
+	size      map[resultLabels]*histogram
 	ticker    *time.Ticker
 	logger    log.Logger
 }
 
+// NewMetrics logs progress every second to the logger, nil discards logs.
 func NewMetrics(logger log.Logger) *metrics {
+	if logger == nil {
+		logger = log.Nop()
+	}
 	m := &metrics{
-		logger: logger,
+		logger:  logger,
+		latency: make(map[resultLabels]*histogram),
+		size:    make(map[resultLabels]*histogram),
 	}
 	m.ticker = time.NewTicker(time.Second)
 	go func() {
 		for range m.ticker.C {
-			m.logger.Log(
-				fmt.Sprintf("\n \u001B[31m requests with timeout: %d \n wait processing: %d \n processed: %d \u001B[0m \n",
-					m.rtimeout, m.submit-m.proc, m.proc))
+			submitted, processed := atomic.LoadUint64(&m.submit), atomic.LoadUint64(&m.proc)
+			m.logger.Info("progress",
+				log.F("requests_with_timeout", atomic.LoadUint64(&m.rtimeout)),
+				log.F("wait_processing", submitted-processed),
+				log.F("processed", processed))
 		}
 	}()
 	//m.timer = time.AfterFunc(1*time.Second, m.Print)
@@ -58,9 +77,62 @@ func (m *metrics) IncDuplicate() {
 	atomic.AddUint64(&m.duplicate, 1)
 }
 
+func (m *metrics) IncDisallowed() {
+	atomic.AddUint64(&m.disallow, 1)
+}
+
+func (m *metrics) IncDepthLimited(cnt int) {
+	atomic.AddUint64(&m.deep, uint64(cnt))
+}
+
+func (m *metrics) IncOutOfScope() {
+	atomic.AddUint64(&m.outScope, 1)
+}
+
+func (m *metrics) IncRetry() {
+	atomic.AddUint64(&m.retry, 1)
+}
+
+func (m *metrics) IncNofollow(cnt int) {
+	atomic.AddUint64(&m.nofollow, uint64(cnt))
+}
+
+func (m *metrics) IncNoIndex() {
+	atomic.AddUint64(&m.noindex, 1)
+}
+
+func (m *metrics) AddQueued(n int) {
+	atomic.AddInt64(&m.queued, int64(n))
+}
+
+func (m *metrics) AddInFlight(n int) {
+	atomic.AddInt64(&m.inFlight, int64(n))
+}
+
+func (m *metrics) ObserveResult(host string, status int, latency time.Duration, size int64) {
+	labels := resultLabels{host: host, status: statusClass(status)}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.latency[labels] == nil {
+		m.latency[labels] = newHistogram(latencyBuckets)
+		m.size[labels] = newHistogram(sizeBuckets)
+	}
+	m.latency[labels].observe(latency.Seconds())
+	m.size[labels].observe(float64(size))
+}
+
 func (m *metrics) Print() {
-	m.logger.Log(
-		fmt.Sprintf("duplicated urls: %d \n requests with timeout: %d \n submitted: %d \n processed: %d \n skipped: %d \n",
-			m.duplicate, m.rtimeout, m.submit, m.proc, m.skip),
+	m.logger.Info("metrics",
+		log.F("duplicated", atomic.LoadUint64(&m.duplicate)),
+		log.F("disallowed", atomic.LoadUint64(&m.disallow)),
+		log.F("depth_limited", atomic.LoadUint64(&m.deep)),
+		log.F("out_of_scope", atomic.LoadUint64(&m.outScope)),
+		log.F("requests_with_timeout", atomic.LoadUint64(&m.rtimeout)),
+		log.F("retries", atomic.LoadUint64(&m.retry)),
+		log.F("nofollow", atomic.LoadUint64(&m.nofollow)),
+		log.F("noindex", atomic.LoadUint64(&m.noindex)),
+		log.F("submitted", atomic.LoadUint64(&m.submit)),
+		log.F("processed", atomic.LoadUint64(&m.proc)),
+		log.F("skipped", atomic.LoadUint64(&m.skip)),
 	)
 }
@@ -1,5 +1,7 @@
 package crawler
 
+import "time"
+
 type MetricMock struct{}
 
 func (m MetricMock) IncDuplicate() {}
@@ -11,3 +13,21 @@ func (m MetricMock) IncSkipped(_ int) {}
 func (m MetricMock) IncSubmitted() {}
 
 func (m MetricMock) IncRequestTimeout() {}
+
+func (m MetricMock) IncDisallowed() {}
+
+func (m MetricMock) IncDepthLimited(_ int) {}
+
+func (m MetricMock) IncOutOfScope() {}
+
+func (m MetricMock) IncRetry() {}
+
+func (m MetricMock) IncNofollow(_ int) {}
+
+func (m MetricMock) IncNoIndex() {}
+
+func (m MetricMock) AddQueued(_ int) {}
+
+func (m MetricMock) AddInFlight(_ int) {}
+
+func (m MetricMock) ObserveResult(_ string, _ int, _ time.Duration, _ int64) {}
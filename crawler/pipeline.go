@@ -0,0 +1,191 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// Handler is a stage of the pipeline every result of Walk passes.
+// The pipeline starts with the link extraction, then runs the handlers
+// of WithHandlers in order and ends with the sinks. Pages are handled
+// in several goroutines at once.
+type Handler interface {
+	// Handle may read the page, change its links, emit urls, annotate
+	// or drop it. An error doesn't stop the pipeline, Walk returns the
+	// first one at the end.
+	Handle(ctx context.Context, page *PageContext) error
+}
+
+type HandlerFunc func(ctx context.Context, page *PageContext) error
+
+func (f HandlerFunc) Handle(ctx context.Context, page *PageContext) error {
+	return f(ctx, page)
+}
+
+// PageContext is a result of the walk passed along the pipeline.
+// Handlers run one after another, so it needs no locking.
+type PageContext struct {
+	Result
+	// Links are found by the link extraction. Followed ones are submitted
+	// after the pipeline, handlers may change them.
+	Links []Link
+	// Robots are the directives of the page honored by the DirectivePolicy.
+	Robots RobotsDirectives
+
+	ctx         context.Context
+	once        sync.Once
+	content     []byte
+	emitted     []URL
+	annotations map[string]interface{}
+	dropped     bool
+}
+
+func newPageContext(ctx context.Context, r Result) *PageContext {
+	return &PageContext{Result: r, ctx: ctx}
+}
+
+// Content returns the body, it is read on the first call. A body cut
+// by an error keeps what was read and the page fails with the error in Err.
+func (c *PageContext) Content() []byte {
+	c.once.Do(func() {
+		var err error
+		if c.content, err = readContent(c.Body); err != nil {
+			c.Err = fetchError(c.ctx, c.URL, err)
+		}
+	})
+	return c.content
+}
+
+// Reader returns a new reader of the body, so every handler can read it.
+func (c *PageContext) Reader() io.Reader {
+	return bytes.NewReader(c.Content())
+}
+
+// Emit submits the urls after the pipeline as if they were linked from the page.
+func (c *PageContext) Emit(urls ...URL) {
+	c.emitted = append(c.emitted, urls...)
+}
+
+// Annotate keeps a value of a handler for the next handlers and the sinks.
+func (c *PageContext) Annotate(key string, value interface{}) {
+	if c.annotations == nil {
+		c.annotations = make(map[string]interface{})
+	}
+	c.annotations[key] = value
+}
+
+func (c *PageContext) Annotation(key string) (interface{}, bool) {
+	v, ok := c.annotations[key]
+	return v, ok
+}
+
+// Drop stops the pipeline: the next handlers and the sinks don't get the page
+// and neither its links nor the emitted urls are submitted.
+func (c *PageContext) Drop() {
+	c.dropped = true
+}
+
+func (c *PageContext) Dropped() bool {
+	return c.dropped
+}
+
+// page makes a Page for the sinks.
+func (c *PageContext) page() Page {
+	return Page{Result: c.Result, Content: c.Content(), Links: c.Links, Annotations: c.annotations, Robots: c.Robots}
+}
+
+// close reads the rest of the body, so it is counted, and closes it.
+func (c *PageContext) close() {
+	c.Content()
+}
+
+// ExtractText annotates pages with their visible text under the key,
+// with collapsed spaces and without scripts and styles.
+func ExtractText(key string) Handler {
+	return HandlerFunc(func(_ context.Context, page *PageContext) error {
+		var b strings.Builder
+		skip := 0
+		tokenizer := html.NewTokenizer(page.Reader())
+		for {
+			switch tokenizer.Next() {
+			case html.ErrorToken:
+				page.Annotate(key, strings.Join(strings.Fields(b.String()), " "))
+				return nil
+			case html.StartTagToken:
+				if tag, _ := tokenizer.TagName(); isHiddenText(string(tag)) {
+					skip++
+				}
+			case html.EndTagToken:
+				if tag, _ := tokenizer.TagName(); isHiddenText(string(tag)) && skip > 0 {
+					skip--
+				}
+			case html.TextToken:
+				if skip == 0 {
+					b.Write(tokenizer.Text())
+					b.WriteByte(' ')
+				}
+			}
+		}
+	})
+}
+
+func isHiddenText(tag string) bool {
+	return tag == "script" || tag == "style" || tag == "noscript" || tag == "template"
+}
+
+// extract is the first stage of the pipeline, it finds the links
+// and the robots directives of the page.
+func (p *processor) extract(_ context.Context, page *PageContext) error {
+	doc := p.extractor.Parse(page.FinalURL, io.NopCloser(page.Reader()))
+	page.Links = doc.Links
+	page.Robots = p.directives.page(page.Header, doc.Robots)
+	return nil
+}
+
+// write is the last stage of the pipeline, it passes the page to the sinks.
+// A page marked noindex is passed too, the sinks decide what to keep of it.
+func (p *processor) write(_ context.Context, page *PageContext) error {
+	if page.Robots.NoIndex {
+		p.metrics.IncNoIndex()
+	}
+	if len(p.sinks) == 0 {
+		return nil
+	}
+	doc := page.page()
+	for _, sink := range p.sinks {
+		p.store("sink", sink.Write(doc))
+	}
+	return nil
+}
+
+// handle runs the pipeline and returns the urls to follow from the page.
+func (p *processor) handle(ctx context.Context, page *PageContext) []URL {
+	defer page.close()
+	stages := append(append([]Handler{HandlerFunc(p.extract)}, p.handlers...), HandlerFunc(p.write))
+	for _, stage := range stages {
+		p.store("handler", stage.Handle(ctx, page))
+		if page.dropped {
+			return nil
+		}
+	}
+	urls := make([]URL, 0, len(page.Links)+len(page.emitted))
+	nofollow := 0
+	for _, link := range page.Links {
+		switch {
+		case !p.follow[link.Kind]:
+		case !p.directives.follows(link, page.Robots):
+			nofollow++
+		default:
+			urls = append(urls, link.URL)
+		}
+	}
+	if nofollow > 0 {
+		p.metrics.IncNofollow(nofollow)
+	}
+	return append(urls, page.emitted...)
+}
@@ -0,0 +1,66 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_processor_walkPipeline(t *testing.T) {
+	ts := newSiteServer(map[string]string{
+		"/":       `<title>Root</title><script>var x = 1</script><a href="/a">a</a><a href="/private">p</a>`,
+		"/a":      `<p>Page <b>a</b></p><a href="/b">b</a>`,
+		"/b":      `page b`,
+		"/hidden": `hidden`,
+	}, 0)
+	defer ts.Close()
+
+	errHandler := errors.New("handler failed")
+	var mu sync.Mutex
+	seen := make(map[string]string)
+	handlers := []Handler{
+		ExtractText("text"),
+		HandlerFunc(func(_ context.Context, page *PageContext) error {
+			// тело читается повторно
+			text, _ := page.Annotation("text")
+			mu.Lock()
+			seen[page.URL.String()] = string(page.Content())
+			mu.Unlock()
+			if strings.HasSuffix(page.URL.String(), "/a") {
+				page.Drop()
+			}
+			links := page.Links[:0]
+			for _, link := range page.Links {
+				if !strings.HasSuffix(link.URL.String(), "/private") {
+					links = append(links, link)
+				}
+			}
+			page.Links = links
+			page.Emit(URL(ts.URL + "/hidden"))
+			page.Annotate("words", len(strings.Fields(text.(string))))
+			return nil
+		}),
+		HandlerFunc(func(_ context.Context, page *PageContext) error {
+			if strings.HasSuffix(page.URL.String(), "/hidden") {
+				return errHandler
+			}
+			return nil
+		}),
+	}
+	sink := &pageRecorder{}
+	w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}), 1, 100, MetricMock{}, nil)
+	p := New(w, MetricMock{}, WithHandlers(handlers...), WithSinks(sink))
+	got, err := p.Walk(context.Background(), []URL{URL(ts.URL + "/")})
+	assert.ErrorIs(t, err, errHandler)
+	assert.Equal(t, 3, got.Fetched, "/, /a and /hidden, /private is removed and /b is on a dropped page")
+	assert.Equal(t, []string{"/", "/hidden"}, sink.paths(), "dropped pages don't reach the sinks")
+	root := sink.pages["/"]
+	assert.Equal(t, map[string]interface{}{"text": "Root a p", "words": 3}, root.Annotations)
+	assert.Contains(t, string(root.Content), "<title>Root</title>")
+	assert.Equal(t, `<p>Page <b>a</b></p><a href="/b">b</a>`, seen[ts.URL+"/a"])
+}
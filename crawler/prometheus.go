@@ -0,0 +1,129 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	sizeBuckets    = []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20}
+)
+
+type resultLabels struct {
+	host   string
+	status string
+}
+
+func (l resultLabels) String() string {
+	return fmt.Sprintf(`host="%s",status="%s"`, escapeLabel(l.host), l.status)
+}
+
+// statusClass is 2xx, 3xx, 4xx, 5xx or error for a failure without response.
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// histogram keeps cumulative counts of observations not greater than a bucket bound.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WritePrometheus(w)
+}
+
+func (m *metrics) WritePrometheus(w io.Writer) error {
+	var b strings.Builder
+	counter := func(name, help string, v *uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, atomic.LoadUint64(v))
+	}
+	gauge := func(name, help string, v int64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, v)
+	}
+	counter("crawler_processed_total", "Tasks completed by the worker.", &m.proc)
+	counter("crawler_skipped_total", "Tasks dropped without a fetch.", &m.skip)
+	counter("crawler_submitted_total", "Tasks accepted by the worker.", &m.submit)
+	counter("crawler_request_timeouts_total", "Requests failed without a response.", &m.rtimeout)
+	counter("crawler_duplicates_total", "Urls found again.", &m.duplicate)
+	counter("crawler_disallowed_total", "Urls disallowed by robots.txt.", &m.disallow)
+	counter("crawler_depth_limited_total", "Urls beyond the max depth.", &m.deep)
+	counter("crawler_out_of_scope_total", "Urls out of the crawl scope.", &m.outScope)
+	counter("crawler_retries_total", "Repeated requests.", &m.retry)
+	counter("crawler_nofollow_total", "Links skipped for a nofollow directive.", &m.nofollow)
+	counter("crawler_noindex_total", "Pages marked noindex.", &m.noindex)
+
+	inFlight := atomic.LoadInt64(&m.inFlight)
+	waiting := atomic.LoadInt64(&m.queued) - inFlight
+	if waiting < 0 {
+		waiting = 0
+	}
+	gauge("crawler_queue_depth", "Urls waiting for a fetch.", waiting)
+	gauge("crawler_in_flight_requests", "Running requests.", inFlight)
+
+	m.mu.Lock()
+	writeHistograms(&b, "crawler_fetch_duration_seconds", "Time from the first request to the response headers.", m.latency)
+	writeHistograms(&b, "crawler_response_size_bytes", "Bytes of a response body.", m.size)
+	m.mu.Unlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHistograms(b *strings.Builder, name, help string, histograms map[resultLabels]*histogram) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	labels := make([]resultLabels, 0, len(histograms))
+	for l := range histograms {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].host != labels[j].host {
+			return labels[i].host < labels[j].host
+		}
+		return labels[i].status < labels[j].status
+	})
+	for _, l := range labels {
+		h := histograms[l]
+		for i, bound := range h.bounds {
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, l, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, l, h.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", name, l, formatFloat(h.sum))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", name, l, h.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,62 @@
+package crawler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_metrics_ServeHTTP(t *testing.T) {
+	m := NewMetrics(nil)
+	defer m.Stop()
+	m.IncSubmitted()
+	m.IncSubmitted()
+	m.IncProcessed()
+	m.IncDuplicate()
+	m.IncNofollow(3)
+	m.AddQueued(5)
+	m.AddQueued(-1)
+	m.AddInFlight(3)
+	m.ObserveResult("b.ru", 200, 200*time.Millisecond, 2048)
+	m.ObserveResult("b.ru", 204, 3*time.Second, 0)
+	m.ObserveResult(`a"ru`, 0, time.Second, 0)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	got := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE crawler_processed_total counter",
+		"crawler_processed_total 1",
+		"crawler_submitted_total 2",
+		"crawler_duplicates_total 1",
+		"crawler_request_timeouts_total 0",
+		"crawler_nofollow_total 3",
+		"crawler_noindex_total 0",
+		"# TYPE crawler_queue_depth gauge",
+		"crawler_queue_depth 1",
+		"crawler_in_flight_requests 3",
+		"# TYPE crawler_fetch_duration_seconds histogram",
+		`crawler_fetch_duration_seconds_bucket{host="b.ru",status="2xx",le="0.25"} 1`,
+		`crawler_fetch_duration_seconds_bucket{host="b.ru",status="2xx",le="2.5"} 1`,
+		`crawler_fetch_duration_seconds_bucket{host="b.ru",status="2xx",le="5"} 2`,
+		`crawler_fetch_duration_seconds_bucket{host="b.ru",status="2xx",le="+Inf"} 2`,
+		`crawler_fetch_duration_seconds_sum{host="b.ru",status="2xx"} 3.2`,
+		`crawler_fetch_duration_seconds_count{host="b.ru",status="2xx"} 2`,
+		`crawler_fetch_duration_seconds_count{host="a\"ru",status="error"} 1`,
+		`crawler_response_size_bytes_bucket{host="b.ru",status="2xx",le="1024"} 1`,
+		`crawler_response_size_bytes_bucket{host="b.ru",status="2xx",le="10240"} 2`,
+		`crawler_response_size_bytes_sum{host="b.ru",status="2xx"} 2048`,
+	} {
+		assert.Contains(t, strings.Split(got, "\n"), line)
+	}
+}
+
+func Test_statusClass(t *testing.T) {
+	for status, want := range map[int]string{0: "error", 200: "2xx", 301: "3xx", 404: "4xx", 503: "5xx", 999: "error"} {
+		assert.Equal(t, want, statusClass(status))
+	}
+}
@@ -0,0 +1,124 @@
+package crawler
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy tells WorkerHandler which failed requests to repeat and how long to wait.
+type RetryPolicy struct {
+	// MaxAttempts is a number of requests for one url including the first one.
+	MaxAttempts int
+	// BaseDelay is a pause before the first retry, it doubles with every next one.
+	BaseDelay time.Duration
+	// MaxDelay caps a pause. A response asking to retry later than MaxDelay
+	// with Retry-After is not retried.
+	MaxDelay time.Duration
+	// Jitter is a part of the pause in [0, 1] that is randomized.
+	Jitter float64
+	// StatusCodes are response codes worth a retry.
+	StatusCodes []int
+	// Retryable reports whether a transport error is worth a retry.
+	Retryable func(err error) bool
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.5,
+	StatusCodes: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+	Retryable: IsTransientError,
+}
+
+// IsTransientError is true for timeouts, connections refused, reset or closed
+// by a server and other failed network operations. Unknown hosts, TLS errors
+// and invalid requests are not transient.
+func IsTransientError(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (p RetryPolicy) retryable(r *http.Response, err error) bool {
+	if err != nil {
+		return p.Retryable != nil && p.Retryable(err)
+	}
+	for _, code := range p.StatusCodes {
+		if r.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns a pause before the retry after attempt,
+// or false when Retry-After asks to wait longer than MaxDelay.
+func (p RetryPolicy) delay(attempt int, r *http.Response) (time.Duration, bool) {
+	d := p.BaseDelay << (attempt - 1)
+	if d <= 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 && d > 0 {
+		d -= time.Duration(rand.Int63n(int64(float64(d)*p.Jitter) + 1))
+	}
+	if r != nil {
+		if after, ok := retryAfter(r.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxDelay > 0 && after > p.MaxDelay {
+				return 0, false
+			}
+			if after > d {
+				d = after
+			}
+		}
+	}
+	return d, true
+}
+
+// retryAfter parses Retry-After given in seconds or as an HTTP date.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(value); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := at.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
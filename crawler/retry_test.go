@@ -0,0 +1,185 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type retryMetrics struct {
+	MetricMock
+	retries int32
+}
+
+func (m *retryMetrics) IncRetry() {
+	atomic.AddInt32(&m.retries, 1)
+}
+
+func TestWorkerHandler_Retry(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    50 * time.Millisecond,
+		StatusCodes: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+		Retryable:   IsTransientError,
+	}
+	tests := []struct {
+		name        string
+		failures    int32
+		status      int
+		retryAfter  string
+		wantStatus  int
+		wantRetries int32
+	}{
+		{name: "успех с первой попытки", failures: 0, status: http.StatusServiceUnavailable, wantStatus: 200},
+		{name: "успех после повтора", failures: 2, status: http.StatusServiceUnavailable, wantStatus: 200, wantRetries: 2},
+		{name: "попытки закончились", failures: 5, status: http.StatusServiceUnavailable, wantStatus: 503, wantRetries: 2},
+		{name: "код не для повтора", failures: 1, status: http.StatusNotFound, wantStatus: 404},
+		{
+			name:        "Retry-After в пределах MaxDelay",
+			failures:    1,
+			status:      http.StatusTooManyRequests,
+			retryAfter:  "0",
+			wantStatus:  200,
+			wantRetries: 1,
+		},
+		{name: "Retry-After больше MaxDelay", failures: 1, status: http.StatusTooManyRequests, retryAfter: "120", wantStatus: 429},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&requests, 1) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer ts.Close()
+
+			m := &retryMetrics{}
+			handler := WorkerHandler(http.Client{}, m, WithRetryPolicy(policy))
+			got := handler(context.Background(), URL(ts.URL))
+			assert.Equal(t, tt.wantStatus, got.StatusCode)
+			assert.Equal(t, tt.wantRetries, atomic.LoadInt32(&m.retries), "retries")
+		})
+	}
+}
+
+func TestWorkerHandler_RetryTimeout(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	m := &retryMetrics{}
+	policy := DefaultRetryPolicy
+	policy.BaseDelay = time.Millisecond
+	handler := WorkerHandler(http.Client{Timeout: 50 * time.Millisecond}, m, WithRetryPolicy(policy))
+	got := handler(context.Background(), URL(ts.URL))
+	assert.Equal(t, http.StatusOK, got.StatusCode)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&m.retries))
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		got, ok := p.delay(attempt+1, nil)
+		assert.True(t, ok)
+		assert.Equalf(t, want, got, "attempt %d", attempt+1)
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		got, _ := p.delay(1, nil)
+		assert.GreaterOrEqual(t, got, 50*time.Millisecond)
+		assert.LessOrEqual(t, got, 100*time.Millisecond)
+	}
+}
+
+func Test_retryAfter(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOk bool
+	}{
+		{value: "", wantOk: false},
+		{value: "5", want: 5 * time.Second, wantOk: true},
+		{value: "-1", wantOk: false},
+		{value: "Mon, 01 Aug 2022 12:00:30 GMT", want: 30 * time.Second, wantOk: true},
+		{value: "Mon, 01 Aug 2022 11:00:00 GMT", want: 0, wantOk: true},
+		{value: "soon", wantOk: false},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value, now)
+		assert.Equalf(t, tt.wantOk, ok, "retryAfter(%q)", tt.value)
+		assert.Equalf(t, tt.want, got, "retryAfter(%q)", tt.value)
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secure.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+	dropped := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		_ = conn.Close()
+	}))
+	defer dropped.Close()
+
+	get := func(client http.Client, u string) error {
+		r, err := client.Get(u)
+		if err == nil {
+			_ = r.Body.Close()
+		}
+		return err
+	}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "таймаут", err: get(http.Client{Timeout: 10 * time.Millisecond}, slow.URL), want: true},
+		{name: "соединение отклонено", err: get(http.Client{}, closed.URL), want: true},
+		{name: "соединение закрыто сервером", err: get(http.Client{}, dropped.URL), want: true},
+		{name: "обрыв тела", err: fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "неизвестный сертификат", err: get(http.Client{}, secure.URL), want: false},
+		{name: "неверная схема", err: get(http.Client{}, "ftp://a.ru/"), want: false},
+		{
+			name: "хост не найден",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "a.test", IsNotFound: true}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.err)
+			assert.Equal(t, tt.want, IsTransientError(tt.err), tt.err)
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package crawler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// RobotsRules is a group of robots.txt rules selected for one user-agent.
+type RobotsRules struct {
+	rules    []robotsRule
+	delay    time.Duration
+	sitemaps []URL
+}
+
+var (
+	robotsAllowAll    = &RobotsRules{}
+	robotsDisallowAll = &RobotsRules{rules: []robotsRule{{allow: false, pattern: "/"}}}
+)
+
+type robotsGroup struct {
+	agents []string
+	RobotsRules
+}
+
+// ParseRobots reads robots.txt and keeps the group that matches userAgent
+// the most specifically, falling back to the "*" group. Sitemap lines
+// don't belong to a group and are kept for any userAgent.
+func ParseRobots(r io.Reader, userAgent string) *RobotsRules {
+	groups := make([]*robotsGroup, 0)
+	var sitemaps []URL
+	var current *robotsGroup
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = &robotsGroup{}
+				groups = append(groups, current)
+				inAgents = true
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			if current == nil || value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsRule{allow: key == "allow", pattern: value})
+		case "crawl-delay":
+			inAgents = false
+			if current == nil {
+				continue
+			}
+			if sec, err := strconv.ParseFloat(value, 64); err == nil && sec > 0 {
+				current.delay = time.Duration(sec * float64(time.Second))
+			}
+		case "sitemap":
+			inAgents = false
+			if value != "" {
+				sitemaps = append(sitemaps, URL(value))
+			}
+		default:
+			inAgents = false
+		}
+	}
+
+	agent := strings.ToLower(userAgent)
+	if i := strings.IndexByte(agent, '/'); i >= 0 {
+		agent = agent[:i]
+	}
+	selected := &RobotsRules{}
+	matched := -1
+
+	for _, g := range groups {
+		length := -1
+		for _, a := range g.agents {
+			switch {
+			case a == "*" && length < 0:
+				length = 0
+			case a != "" && a != "*" && strings.Contains(agent, a) && len(a) > length:
+				length = len(a)
+			}
+		}
+		if length < 0 || length < matched {
+			continue
+		}
+		if length > matched {
+			selected = &RobotsRules{}
+			matched = length
+		}
+		selected.rules = append(selected.rules, g.rules...)
+		if g.delay > selected.delay {
+			selected.delay = g.delay
+		}
+	}
+	selected.sitemaps = sitemaps
+	return selected
+}
+
+// Allowed reports whether path (with query) may be fetched.
+// The longest matching rule wins, allow wins a tie.
+func (r *RobotsRules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	allow, length := true, -1
+	for _, rule := range r.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		if l := len(rule.pattern); l > length || (l == length && rule.allow) {
+			allow, length = rule.allow, l
+		}
+	}
+	return allow
+}
+
+func (r *RobotsRules) CrawlDelay() time.Duration {
+	return r.delay
+}
+
+// Sitemaps returns the urls of the Sitemap lines.
+func (r *RobotsRules) Sitemaps() []URL {
+	return r.sitemaps
+}
+
+// matchRobotsPattern matches path against a robots.txt pattern,
+// where '*' is any sequence of characters and a trailing '$' anchors the end.
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for i := 1; i < len(parts); i++ {
+		if i == len(parts)-1 && anchored {
+			return strings.HasSuffix(path[pos:], parts[i])
+		}
+		idx := strings.Index(path[pos:], parts[i])
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(parts[i])
+	}
+	return !anchored || pos == len(path)
+}
+
+type robotsEntry struct {
+	rules   *RobotsRules
+	expires time.Time
+	ready   chan struct{}
+}
+
+// robots fetches /robots.txt once per scheme and host, as http and https
+// of a host may serve different rules, and keeps it for ttl.
+type robots struct {
+	client    http.Client
+	userAgent string
+	ttl       time.Duration
+	mu        sync.Mutex
+	cache     map[string]*robotsEntry
+}
+
+func NewRobots(client http.Client, userAgent string, ttl time.Duration) *robots {
+	return &robots{
+		client:    client,
+		userAgent: userAgent,
+		ttl:       ttl,
+		cache:     make(map[string]*robotsEntry),
+	}
+}
+
+func (r *robots) Allowed(u URL) bool {
+	parsed, err := url.Parse(u.String())
+	if err != nil || parsed.Host == "" {
+		return true
+	}
+	return r.rules(parsed).Allowed(parsed.RequestURI())
+}
+
+// Sitemaps returns the sitemaps listed in robots.txt of the site of u.
+func (r *robots) Sitemaps(u URL) []URL {
+	parsed, err := url.Parse(u.String())
+	if err != nil || parsed.Host == "" {
+		return nil
+	}
+	return r.rules(parsed).Sitemaps()
+}
+
+// CrawlDelay returns a Crawl-delay of a host that was already fetched,
+// the longer one when both http and https of the host were fetched.
+func (r *robots) CrawlDelay(host string) time.Duration {
+	var delay time.Duration
+	for _, scheme := range []string{"http", "https"} {
+		r.mu.Lock()
+		e, ok := r.cache[robotsKey(scheme, host)]
+		r.mu.Unlock()
+		if !ok {
+			continue
+		}
+		select {
+		case <-e.ready:
+			if d := e.rules.CrawlDelay(); d > delay {
+				delay = d
+			}
+		default:
+		}
+	}
+	return delay
+}
+
+func robotsKey(scheme, host string) string {
+	return strings.ToLower(scheme) + "://" + strings.ToLower(host)
+}
+
+func (r *robots) rules(u *url.URL) *RobotsRules {
+	key := robotsKey(u.Scheme, u.Host)
+	r.mu.Lock()
+	e, ok := r.cache[key]
+	if ok {
+		select {
+		case <-e.ready:
+			if time.Now().After(e.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		e = &robotsEntry{ready: make(chan struct{})}
+		r.cache[key] = e
+		r.mu.Unlock()
+		e.rules = r.fetch(u.Scheme, u.Host)
+		e.expires = time.Now().Add(r.ttl)
+		close(e.ready)
+		return e.rules
+	}
+	r.mu.Unlock()
+	<-e.ready
+	return e.rules
+}
+
+func (r *robots) fetch(scheme, host string) *RobotsRules {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, fmt.Sprintf("%s://%s/robots.txt", scheme, host), nil)
+	if err != nil {
+		return robotsAllowAll
+	}
+	if r.userAgent != "" {
+		req.Header.Set("User-Agent", r.userAgent)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return robotsDisallowAll
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return ParseRobots(io.LimitReader(resp.Body, 500<<10), r.userAgent)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return robotsAllowAll
+	default:
+		return robotsDisallowAll
+	}
+}
@@ -0,0 +1,152 @@
+package crawler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const robotsTxt = `
+# comment
+User-agent: *
+Disallow: /private
+Allow: /private/open
+Disallow: /*.pdf$
+
+User-agent: crawler
+User-agent: other
+Disallow: /search
+Allow: /search/about
+Crawl-delay: 1.5
+
+User-agent: BadBot
+Disallow: /
+`
+
+func TestParseRobots(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		path      string
+		want      bool
+	}{
+		{name: "общая группа: разрешено", userAgent: "somebot", path: "/wiki", want: true},
+		{name: "общая группа: запрещено", userAgent: "somebot", path: "/private/page", want: false},
+		{name: "более длинное правило разрешает", userAgent: "somebot", path: "/private/open/page", want: true},
+		{name: "шаблон с якорем", userAgent: "somebot", path: "/docs/file.pdf", want: false},
+		{name: "шаблон с якорем не совпал", userAgent: "somebot", path: "/docs/file.pdf?x=1", want: true},
+		{name: "своя группа заменяет общую", userAgent: "Crawler/1.0", path: "/private/page", want: true},
+		{name: "своя группа: запрещено", userAgent: "Crawler/1.0", path: "/search?q=go", want: false},
+		{name: "своя группа: разрешено", userAgent: "Crawler/1.0", path: "/search/about", want: true},
+		{name: "полный запрет", userAgent: "badbot", path: "/", want: false},
+		{name: "robots.txt всегда разрешен", userAgent: "badbot", path: "/robots.txt", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := ParseRobots(strings.NewReader(robotsTxt), tt.userAgent)
+			assert.Equalf(t, tt.want, rules.Allowed(tt.path), "Allowed(%s)", tt.path)
+		})
+	}
+}
+
+func TestParseRobots_CrawlDelay(t *testing.T) {
+	assert.Equal(t, 1500*time.Millisecond, ParseRobots(strings.NewReader(robotsTxt), "crawler").CrawlDelay())
+	assert.Equal(t, time.Duration(0), ParseRobots(strings.NewReader(robotsTxt), "somebot").CrawlDelay())
+}
+
+func TestParseRobots_Sitemaps(t *testing.T) {
+	txt := "Sitemap: https://a.ru/sitemap.xml\n" + robotsTxt + "sitemap:https://a.ru/news.xml.gz # новости\n"
+	want := []URL{"https://a.ru/sitemap.xml", "https://a.ru/news.xml.gz"}
+	assert.Equal(t, want, ParseRobots(strings.NewReader(txt), "crawler").Sitemaps())
+	assert.Equal(t, want, ParseRobots(strings.NewReader(txt), "badbot").Sitemaps())
+	assert.Empty(t, ParseRobots(strings.NewReader(robotsTxt), "crawler").Sitemaps())
+}
+
+func TestRobots_Allowed(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		atomic.AddInt32(&hits, 1)
+		assert.Equal(t, "crawler", r.UserAgent())
+		_, _ = fmt.Fprint(w, robotsTxt)
+	}))
+	defer ts.Close()
+
+	r := NewRobots(http.Client{}, "crawler", time.Hour)
+	assert.True(t, r.Allowed(URL(ts.URL+"/private/page")))
+	assert.False(t, r.Allowed(URL(ts.URL+"/search?q=go")))
+	assert.True(t, r.Allowed(URL(ts.URL+"/search/about")))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits), "robots.txt is fetched once per host")
+	assert.Equal(t, 1500*time.Millisecond, r.CrawlDelay(URL(ts.URL).Host()))
+}
+
+// roundTripFunc answers requests of a client without a server.
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRobots_Scheme(t *testing.T) {
+	rules := map[string]string{
+		"http":  "User-agent: *\nDisallow: /\nCrawl-delay: 2",
+		"https": "User-agent: *\nDisallow: /private\nCrawl-delay: 1",
+	}
+	client := http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(rules[req.URL.Scheme])),
+			Request:    req,
+		}, nil
+	})}
+	r := NewRobots(client, "crawler", time.Hour)
+
+	tests := []struct {
+		name string
+		url  URL
+		want bool
+	}{
+		{name: "правила https", url: "https://site.test/page", want: true},
+		{name: "правила http того же хоста", url: "http://site.test/page", want: false},
+		{name: "запрет https", url: "https://site.test/private", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.Allowed(tt.url))
+		})
+	}
+	assert.Equal(t, 2*time.Second, r.CrawlDelay("site.test"), "the longer delay of the schemes")
+}
+
+func TestRobots_FetchStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   bool
+	}{
+		{name: "robots.txt не найден", status: http.StatusNotFound, want: true},
+		{name: "ошибка сервера", status: http.StatusInternalServerError, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer ts.Close()
+
+			r := NewRobots(http.Client{}, "crawler", time.Hour)
+			assert.Equal(t, tt.want, r.Allowed(URL(ts.URL+"/page")))
+		})
+	}
+}
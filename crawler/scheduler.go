@@ -0,0 +1,283 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HostPolicy limits how hard a single host is hit.
+type HostPolicy struct {
+	// MaxConcurrent is a number of simultaneous requests to the same host.
+	MaxConcurrent int
+	// Delay is a minimum pause between requests to the same host.
+	Delay time.Duration
+	// CrawlDelay overrides Delay for hosts that ask for a longer pause.
+	CrawlDelay CrawlDelayer
+	// Breaker pauses hosts that keep failing.
+	Breaker BreakerPolicy
+	// MaxQueued is a number of tasks waiting in the host queues,
+	// SubmitTasks blocks while it is reached. 0 is for no limit.
+	MaxQueued int
+}
+
+type CrawlDelayer interface {
+	CrawlDelay(host string) time.Duration
+}
+
+func (p HostPolicy) delay(host string) time.Duration {
+	if p.CrawlDelay == nil {
+		return p.Delay
+	}
+	if d := p.CrawlDelay.CrawlDelay(host); d > p.Delay {
+		return d
+	}
+	return p.Delay
+}
+
+type hostQueue struct {
+	name   string
+	tasks  []Task
+	active int
+	next   time.Time
+}
+
+// hostScheduler holds submitted urls in per-host queues and releases them
+// to the wrapped worker round-robin, respecting the HostPolicy.
+type hostScheduler struct {
+	worker  Worker
+	results <-chan Result
+	policy  HostPolicy
+	mu      sync.Mutex
+	space   *sync.Cond
+	queued  int
+	hosts   map[string]*hostQueue
+	order   []*hostQueue
+	cursor  int
+	stats   map[string]*hostStats
+	closed  bool
+	wake    chan struct{}
+	started chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func NewHostScheduler(workFn WorkerFunc, policy HostPolicy, newWorker func(WorkerFunc) Worker) *hostScheduler {
+	if policy.MaxConcurrent <= 0 {
+		policy.MaxConcurrent = 1
+	}
+	s := &hostScheduler{
+		policy:  policy,
+		hosts:   make(map[string]*hostQueue),
+		stats:   make(map[string]*hostStats),
+		wake:    make(chan struct{}, 1),
+		started: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	s.space = sync.NewCond(&s.mu)
+	s.worker = newWorker(s.wrap(workFn))
+	s.results = s.worker.SubmitTasks(nil)
+	go s.dispatch()
+	return s
+}
+
+func (s *hostScheduler) Shutdown() {
+	s.stop()
+	s.worker.Shutdown()
+}
+
+// GracefulShutdown drops the queued tasks and lets the ones
+// already released to the worker complete.
+func (s *hostScheduler) GracefulShutdown() {
+	s.stop()
+	s.worker.GracefulShutdown()
+}
+
+func (s *hostScheduler) stop() {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.hosts = make(map[string]*hostQueue)
+		s.order = nil
+		s.queued = 0
+		s.space.Broadcast()
+		s.mu.Unlock()
+		close(s.done)
+		signal(s.started)
+	})
+}
+
+// SubmitTasks puts tasks to the host queues. It blocks while
+// MaxQueued tasks are waiting and drops the rest of tasks
+// when the scheduler is shut down.
+func (s *hostScheduler) SubmitTasks(tasks []Task) <-chan Result {
+	if len(tasks) == 0 {
+		return s.results
+	}
+	s.enqueue(tasks)
+	s.notify()
+	return s.results
+}
+
+func (s *hostScheduler) enqueue(tasks []Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range tasks {
+		for !s.closed && s.policy.MaxQueued > 0 && s.queued >= s.policy.MaxQueued {
+			// the dispatcher takes the queued tasks meanwhile
+			s.notify()
+			s.space.Wait()
+		}
+		if s.closed {
+			return
+		}
+		s.queued++
+		host := t.URL.Host()
+		h, ok := s.hosts[host]
+		if !ok {
+			h = &hostQueue{name: host}
+			s.hosts[host] = h
+			s.order = append(s.order, h)
+		}
+		h.tasks = append(h.tasks, t)
+	}
+}
+
+// Ready tells whether no task of the host waits in its queue. A task
+// of a ready host waits there only for the delay or a free slot.
+func (s *hostScheduler) Ready(host string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.hosts[host]
+	return !ok || len(h.tasks) == 0
+}
+
+// Wake is signalled when tasks are released to the worker.
+func (s *hostScheduler) Wake() <-chan struct{} {
+	return s.started
+}
+
+func (s *hostScheduler) wrap(workFn WorkerFunc) WorkerFunc {
+	return func(ctx context.Context, url URL) Result {
+		host := url.Host()
+		defer s.release(host)
+		return s.record(host, workFn(ctx, url), time.Now())
+	}
+}
+
+func (s *hostScheduler) release(host string) {
+	s.mu.Lock()
+	if h, ok := s.hosts[host]; ok {
+		h.active--
+		if next := time.Now().Add(s.policy.delay(host)); next.After(h.next) {
+			h.next = next
+		}
+	}
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *hostScheduler) notify() {
+	signal(s.wake)
+}
+
+// signal wakes the goroutine waiting on ch unless it is woken already.
+func signal(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (s *hostScheduler) dispatch() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		tasks, wait := s.ready(time.Now())
+		if len(tasks) > 0 {
+			s.worker.SubmitTasks(tasks)
+			signal(s.started)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if wait > 0 {
+			timer.Reset(wait)
+		}
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// ready takes at most one task per host in a round, starting from the host
+// next to the one served last, until no host can accept a request.
+// It returns the released tasks and the time until some delayed host is free.
+func (s *hostScheduler) ready(now time.Time) ([]Task, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compact(now)
+
+	var tasks []Task
+	var wait time.Duration
+	for released := true; released && len(s.order) > 0; {
+		released = false
+		wait = 0
+		start := s.cursor
+		for i := 0; i < len(s.order); i++ {
+			idx := (start + i) % len(s.order)
+			h := s.order[idx]
+			if len(h.tasks) == 0 || h.active >= s.policy.MaxConcurrent {
+				continue
+			}
+			if paused, d := s.paused(h, now); paused {
+				if d > 0 && (wait == 0 || d < wait) {
+					wait = d
+				}
+				continue
+			}
+			if d := h.next.Sub(now); d > 0 {
+				if wait == 0 || d < wait {
+					wait = d
+				}
+				continue
+			}
+			tasks = append(tasks, h.tasks[0])
+			h.tasks = h.tasks[1:]
+			s.queued--
+			h.active++
+			h.next = now.Add(s.policy.delay(h.name))
+			s.cursor = (idx + 1) % len(s.order)
+			released = true
+		}
+	}
+	if len(tasks) > 0 && s.policy.MaxQueued > 0 {
+		s.space.Broadcast()
+	}
+	return tasks, wait
+}
+
+// compact forgets hosts that have nothing to do and whose delay is over.
+func (s *hostScheduler) compact(now time.Time) {
+	order := s.order[:0]
+	for _, h := range s.order {
+		if len(h.tasks) == 0 && h.active == 0 && !h.next.After(now) {
+			delete(s.hosts, h.name)
+			continue
+		}
+		order = append(order, h)
+	}
+	for i := len(order); i < len(s.order); i++ {
+		s.order[i] = nil
+	}
+	s.order = order
+	if len(s.order) == 0 || s.cursor >= len(s.order) {
+		s.cursor = 0
+	}
+}
@@ -0,0 +1,173 @@
+package crawler
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hostProbe struct {
+	mu      sync.Mutex
+	active  map[string]int
+	maxSeen map[string]int
+	started map[string][]time.Time
+	order   []string
+}
+
+func newHostProbe() *hostProbe {
+	return &hostProbe{
+		active:  make(map[string]int),
+		maxSeen: make(map[string]int),
+		started: make(map[string][]time.Time),
+	}
+}
+
+func (p *hostProbe) workFn(execTime time.Duration) WorkerFunc {
+	return func(ctx context.Context, url URL) Result {
+		host := url.Host()
+		p.mu.Lock()
+		p.active[host]++
+		if p.active[host] > p.maxSeen[host] {
+			p.maxSeen[host] = p.active[host]
+		}
+		p.started[host] = append(p.started[host], time.Now())
+		p.order = append(p.order, host)
+		p.mu.Unlock()
+		defer func() {
+			p.mu.Lock()
+			p.active[host]--
+			p.mu.Unlock()
+		}()
+		select {
+		case <-time.After(execTime):
+			return resultOK
+		case <-ctx.Done():
+			return resultCANCEL
+		}
+	}
+}
+
+func collect(t *testing.T, out <-chan Result, n int) []Result {
+	actual := make([]Result, 0, n)
+	timeout := time.After(5 * time.Second)
+	for len(actual) < n {
+		select {
+		case r := <-out:
+			actual = append(actual, r)
+		case <-timeout:
+			t.Fatalf("received %d of %d results", len(actual), n)
+		}
+	}
+	return actual
+}
+
+func Test_HostSchedulerPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   HostPolicy
+		urls     []URL
+		wantMax  map[string]int
+		minDelay time.Duration
+	}{
+		{
+			name:   "один запрос к хосту одновременно",
+			policy: HostPolicy{MaxConcurrent: 1},
+			urls: []URL{
+				"https://example.com/1", "https://example.com/2", "https://example.com/3",
+				"https://google.com/1", "https://google.com/2",
+			},
+			wantMax: map[string]int{"example.com": 1, "google.com": 1},
+		},
+		{
+			name:   "два запроса к хосту одновременно",
+			policy: HostPolicy{MaxConcurrent: 2},
+			urls: []URL{
+				"https://example.com/1", "https://example.com/2", "https://example.com/3", "https://example.com/4",
+			},
+			wantMax: map[string]int{"example.com": 2},
+		},
+		{
+			name:   "задержка между запросами к хосту",
+			policy: HostPolicy{MaxConcurrent: 1, Delay: 30 * time.Millisecond},
+			urls: []URL{
+				"https://example.com/1", "https://example.com/2", "https://example.com/3",
+			},
+			wantMax:  map[string]int{"example.com": 1},
+			minDelay: 30 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			probe := newHostProbe()
+			s := NewHostScheduler(probe.workFn(10*time.Millisecond), tt.policy, func(fn WorkerFunc) Worker {
+				return NewWorkerV2(fn, 10, 100, MetricMock{}, nil)
+			})
+			out := s.SubmitTasks(URLs(tt.urls).Tasks(0))
+			actual := collect(t, out, len(tt.urls))
+			s.Shutdown()
+
+			assert.Len(t, actual, len(tt.urls))
+			assert.Equal(t, tt.wantMax, probe.maxSeen, "max concurrent requests per host")
+			for host, started := range probe.started {
+				for i := 1; i < len(started); i++ {
+					assert.GreaterOrEqualf(t, started[i].Sub(started[i-1]), tt.minDelay, "delay for %s", host)
+				}
+			}
+		})
+	}
+}
+
+func Test_HostSchedulerReady(t *testing.T) {
+	now := time.Now()
+	s := &hostScheduler{
+		policy: HostPolicy{MaxConcurrent: 2, Delay: time.Second},
+		hosts:  make(map[string]*hostQueue),
+	}
+	s.enqueue(URLs{"https://a.com/1", "https://a.com/2", "https://a.com/3"}.Tasks(0))
+	s.enqueue(URLs{"https://b.com/1", "https://b.com/2"}.Tasks(1))
+
+	tasks, wait := s.ready(now)
+	assert.Equal(t, []Task{{URL: "https://a.com/1"}, {URL: "https://b.com/1", Depth: 1}}, tasks, "one url per host in a round")
+	assert.Equal(t, time.Second, wait)
+
+	tasks, _ = s.ready(now.Add(time.Second))
+	assert.Equal(t, []Task{{URL: "https://a.com/2"}, {URL: "https://b.com/2", Depth: 1}}, tasks, "hosts are served in turn")
+
+	tasks, _ = s.ready(now.Add(2 * time.Second))
+	assert.Empty(t, tasks, "host concurrency limit reached")
+
+	s.release("a.com")
+	tasks, _ = s.ready(time.Now().Add(2 * time.Second))
+	assert.Equal(t, []Task{{URL: "https://a.com/3"}}, tasks)
+}
+
+func Test_HostSchedulerMaxQueued(t *testing.T) {
+	gate := make(chan struct{})
+	s := NewHostScheduler(func(ctx context.Context, url URL) Result {
+		<-gate
+		return resultOK
+	}, HostPolicy{MaxConcurrent: 1, MaxQueued: 2}, func(fn WorkerFunc) Worker {
+		return NewWorkerV2(fn, 10, 100, MetricMock{}, nil)
+	})
+	defer s.Shutdown()
+	out := s.SubmitTasks(URLs{"https://a.com/1"}.Tasks(0))
+
+	submitted := make(chan struct{})
+	go func() {
+		defer close(submitted)
+		s.SubmitTasks(URLs{"https://a.com/2", "https://a.com/3", "https://a.com/4"}.Tasks(0))
+	}()
+	select {
+	case <-submitted:
+		t.Fatal("a full scheduler accepted a task")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(gate)
+	<-submitted
+	assert.Len(t, collect(t, out, 4), 4)
+}
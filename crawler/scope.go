@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+type ScopeMode int
+
+const (
+	// ScopeAny follows links to any host unless an allow list is given.
+	ScopeAny ScopeMode = iota
+	// ScopeSameHost stays on the hosts of the seeds.
+	ScopeSameHost
+	// ScopeSameDomain stays on the registrable domains of the seeds
+	// including their subdomains.
+	ScopeSameDomain
+)
+
+type scopePattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// match checks a glob against the host and a regexp against the whole url.
+func (p scopePattern) match(u URL, host string) bool {
+	if p.re != nil {
+		return p.re.MatchString(u.String())
+	}
+	ok, _ := path.Match(p.glob, host)
+	return ok
+}
+
+// scope decides which links are followed. A link is in scope when it doesn't
+// match the deny list and either matches the allow list or the mode.
+type scope struct {
+	mode  ScopeMode
+	allow []scopePattern
+	deny  []scopePattern
+	mu    sync.RWMutex
+	seeds map[string]struct{}
+}
+
+// NewScope compiles allow and deny patterns. A pattern with the "re:" prefix
+// is a regular expression for the url, any other is a glob for the host,
+// e.g. "*.wikipedia.org".
+func NewScope(mode ScopeMode, allow []string, deny []string) (*scope, error) {
+	s := &scope{mode: mode, seeds: make(map[string]struct{})}
+	var err error
+	if s.allow, err = compileScopePatterns(allow); err != nil {
+		return nil, err
+	}
+	if s.deny, err = compileScopePatterns(deny); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func compileScopePatterns(patterns []string) ([]scopePattern, error) {
+	compiled := make([]scopePattern, 0, len(patterns))
+	for _, p := range patterns {
+		if expr, ok := cutPrefix(p, "re:"); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("scope pattern %q: %w", p, err)
+			}
+			compiled = append(compiled, scopePattern{re: re})
+			continue
+		}
+		glob := strings.ToLower(p)
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("scope pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, scopePattern{glob: glob})
+	}
+	return compiled, nil
+}
+
+func (s *scope) Seed(urls []URL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range urls {
+		if key := s.key(hostname(u.Host())); key != "" {
+			s.seeds[key] = struct{}{}
+		}
+	}
+}
+
+func (s *scope) InScope(u URL) bool {
+	host := hostname(u.Host())
+	for _, p := range s.deny {
+		if p.match(u, host) {
+			return false
+		}
+	}
+	for _, p := range s.allow {
+		if p.match(u, host) {
+			return true
+		}
+	}
+	if s.mode == ScopeAny {
+		return len(s.allow) == 0
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.seeds[s.key(host)]
+	return ok
+}
+
+// key is a host or its registrable domain depending on the mode.
+func (s *scope) key(host string) string {
+	if s.mode != ScopeSameDomain {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+// hostname drops a port from the host.
+func hostname(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		return host[:i]
+	}
+	return host
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,70 @@
+package crawler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope_InScope(t *testing.T) {
+	seeds := []URL{"https://habr.com/ru/", "https://ru.wikipedia.org/wiki/Go"}
+	tests := []struct {
+		name  string
+		mode  ScopeMode
+		allow []string
+		deny  []string
+		url   URL
+		want  bool
+	}{
+		{name: "любой хост", mode: ScopeAny, url: "https://google.com", want: true},
+		{name: "хост сида", mode: ScopeSameHost, url: "https://habr.com/ru/post/1", want: true},
+		{name: "хост сида с портом", mode: ScopeSameHost, url: "https://habr.com:443/ru/post/1", want: true},
+		{name: "поддомен вне хоста", mode: ScopeSameHost, url: "https://en.wikipedia.org/wiki/Go", want: false},
+		{name: "поддомен домена", mode: ScopeSameDomain, url: "https://en.wikipedia.org/wiki/Go", want: true},
+		{name: "чужой домен", mode: ScopeSameDomain, url: "https://google.com", want: false},
+		{
+			name:  "разрешенный glob",
+			mode:  ScopeSameHost,
+			allow: []string{"*.google.com"},
+			url:   "https://mail.google.com",
+			want:  true,
+		},
+		{
+			name:  "только список разрешенных",
+			mode:  ScopeAny,
+			allow: []string{"*.google.com"},
+			url:   "https://habr.com",
+			want:  false,
+		},
+		{
+			name: "запрещенный regexp",
+			mode: ScopeSameHost,
+			deny: []string{`re:/ru/users/`},
+			url:  "https://habr.com/ru/users/someone/",
+			want: false,
+		},
+		{
+			name:  "запрет сильнее разрешения",
+			mode:  ScopeAny,
+			allow: []string{"*.wikipedia.org"},
+			deny:  []string{"ru.wikipedia.org"},
+			url:   "https://ru.wikipedia.org/wiki/Go",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewScope(tt.mode, tt.allow, tt.deny)
+			assert.NoError(t, err)
+			s.Seed(seeds)
+			assert.Equalf(t, tt.want, s.InScope(tt.url), "InScope(%s)", tt.url)
+		})
+	}
+}
+
+func TestNewScope_InvalidPattern(t *testing.T) {
+	_, err := NewScope(ScopeAny, []string{"re:("}, nil)
+	assert.Error(t, err)
+	_, err = NewScope(ScopeAny, nil, []string{"[a-"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,34 @@
+package crawler
+
+import (
+	"io"
+)
+
+// Page is a result of the walk with the body read into Content.
+type Page struct {
+	Result
+	Content []byte
+	// Links are found on the page.
+	Links []Link
+	// Annotations are set by the handlers of the pipeline.
+	Annotations map[string]interface{}
+	// Robots are the directives of the page honored by the DirectivePolicy.
+	// Sinks of page copies, like WARC, skip a page marked noindex.
+	Robots RobotsDirectives
+}
+
+// Sink stores results of the walk. Write is called from several
+// goroutines and must not keep Content after it returns.
+type Sink interface {
+	Write(page Page) error
+}
+
+// readContent reads and closes the body. A body cut by an error
+// keeps what was read.
+func readContent(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
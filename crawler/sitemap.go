@@ -0,0 +1,183 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sitemapMaxSize is the size limit of an uncompressed sitemap by sitemaps.org.
+const sitemapMaxSize = 50 << 20
+
+// SitemapURL is a url listed in a sitemap.
+type SitemapURL struct {
+	URL URL
+	// LastMod is zero when the sitemap doesn't tell it.
+	LastMod time.Time
+	// Priority is from 0 to 1, 0.5 when the sitemap doesn't tell it.
+	Priority float64
+	// Sitemap is the sitemap the url is listed in.
+	Sitemap URL
+}
+
+// ParseSitemap reads a urlset or a sitemap index, plain or gzipped.
+// It returns the urls of a urlset and the sitemaps of an index.
+func ParseSitemap(r io.Reader) (urls []SitemapURL, sitemaps []URL, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+	dec := xml.NewDecoder(io.LimitReader(r, sitemapMaxSize))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return urls, sitemaps, nil
+		}
+		if err != nil {
+			return urls, sitemaps, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "url":
+			var entry struct {
+				Loc      string `xml:"loc"`
+				LastMod  string `xml:"lastmod"`
+				Priority string `xml:"priority"`
+			}
+			if err := dec.DecodeElement(&entry, &start); err != nil {
+				return urls, sitemaps, err
+			}
+			if loc := strings.TrimSpace(entry.Loc); loc != "" {
+				urls = append(urls, SitemapURL{
+					URL:      URL(loc),
+					LastMod:  parseLastMod(entry.LastMod),
+					Priority: parsePriority(entry.Priority),
+				})
+			}
+		case "sitemap":
+			var entry struct {
+				Loc string `xml:"loc"`
+			}
+			if err := dec.DecodeElement(&entry, &start); err != nil {
+				return urls, sitemaps, err
+			}
+			if loc := strings.TrimSpace(entry.Loc); loc != "" {
+				sitemaps = append(sitemaps, URL(loc))
+			}
+		}
+	}
+}
+
+// parseLastMod reads a W3C datetime, from a date to a time with fractions of a second.
+func parseLastMod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04Z07:00", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func parsePriority(s string) float64 {
+	p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || p < 0 || p > 1 {
+		return 0.5
+	}
+	return p
+}
+
+// SitemapLister lists the sitemaps of a site and tells whether a sitemap
+// may be fetched, e.g. by robots.txt.
+type SitemapLister interface {
+	Sitemaps(u URL) []URL
+	Allowed(u URL) bool
+}
+
+// sitemaps finds the urls of a site in its sitemaps.
+type sitemaps struct {
+	client http.Client
+	robots SitemapLister
+	limit  int
+}
+
+// NewSitemaps reads the sitemaps listed by robots and /sitemap.xml of a site,
+// following sitemap indexes. It keeps up to limit urls of a site, 0 for no
+// limit. robots may be nil.
+func NewSitemaps(client http.Client, robots SitemapLister, limit int) *sitemaps {
+	return &sitemaps{client: client, robots: robots, limit: limit}
+}
+
+// URLs returns the urls listed in the sitemaps of the site of seed.
+// Sitemaps of other hosts and ones disallowed by robots are skipped,
+// of a sitemap that fails to load the urls read before the error are kept.
+func (s *sitemaps) URLs(ctx context.Context, seed URL) []SitemapURL {
+	parsed, err := url.Parse(seed.String())
+	if err != nil || parsed.Host == "" {
+		return nil
+	}
+	var queue []URL
+	if s.robots != nil {
+		queue = append(queue, s.robots.Sitemaps(seed)...)
+	}
+	queue = append(queue, URL(fmt.Sprintf("%s://%s/sitemap.xml", parsed.Scheme, parsed.Host)))
+
+	var found []SitemapURL
+	visited := make(map[URL]bool)
+	for len(queue) > 0 && ctx.Err() == nil {
+		sitemap := queue[0]
+		queue = queue[1:]
+		if visited[sitemap] {
+			continue
+		}
+		visited[sitemap] = true
+		if sitemap.Host() != strings.ToLower(parsed.Host) || (s.robots != nil && !s.robots.Allowed(sitemap)) {
+			continue
+		}
+		urls, indexed, _ := s.fetch(ctx, sitemap)
+		queue = append(queue, indexed...)
+		for _, u := range urls {
+			if s.limit > 0 && len(found) >= s.limit {
+				return found
+			}
+			u.Sitemap = sitemap
+			found = append(found, u)
+		}
+	}
+	return found
+}
+
+func (s *sitemaps) fetch(ctx context.Context, sitemap URL) ([]SitemapURL, []URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemap.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("sitemap %s: %s", sitemap, resp.Status)
+	}
+	return ParseSitemap(resp.Body)
+}
@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const urlsetXML = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://a.ru/</loc><lastmod>2022-08-01</lastmod><priority>1.0</priority></url>
+  <url>
+    <loc> https://a.ru/news?id=1&amp;page=2 </loc>
+    <lastmod>2022-08-02T10:30:00+03:00</lastmod>
+  </url>
+  <url><loc>https://a.ru/old</loc><lastmod>вчера</lastmod><priority>7</priority></url>
+  <url><loc></loc></url>
+</urlset>`
+
+func gzipped(s string) string {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	_, _ = gz.Write([]byte(s))
+	_ = gz.Close()
+	return b.String()
+}
+
+func TestParseSitemap(t *testing.T) {
+	want := []SitemapURL{
+		{URL: "https://a.ru/", LastMod: time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC), Priority: 1},
+		{URL: "https://a.ru/news?id=1&page=2", LastMod: time.Date(2022, 8, 2, 7, 30, 0, 0, time.UTC), Priority: 0.5},
+		{URL: "https://a.ru/old", Priority: 0.5},
+	}
+	for name, content := range map[string]string{"xml": urlsetXML, "gzip": gzipped(urlsetXML)} {
+		t.Run(name, func(t *testing.T) {
+			urls, sitemaps, err := ParseSitemap(strings.NewReader(content))
+			assert.NoError(t, err)
+			assert.Empty(t, sitemaps)
+			if assert.Len(t, urls, len(want)) {
+				for i := range want {
+					assert.Equal(t, want[i].URL, urls[i].URL)
+					assert.True(t, want[i].LastMod.Equal(urls[i].LastMod), "lastmod of %s: %v", urls[i].URL, urls[i].LastMod)
+					assert.Equal(t, want[i].Priority, urls[i].Priority)
+				}
+			}
+		})
+	}
+
+	urls, sitemaps, err := ParseSitemap(strings.NewReader(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://a.ru/s1.xml.gz</loc><lastmod>2022-08-01</lastmod></sitemap>
+  <sitemap><loc>https://a.ru/s2.xml</loc></sitemap>
+</sitemapindex>`))
+	assert.NoError(t, err)
+	assert.Empty(t, urls)
+	assert.Equal(t, []URL{"https://a.ru/s1.xml.gz", "https://a.ru/s2.xml"}, sitemaps)
+
+	_, _, err = ParseSitemap(strings.NewReader(`<urlset><url><loc>https://a.ru/`))
+	assert.Error(t, err)
+}
+
+func TestSitemaps_URLs(t *testing.T) {
+	pages := map[string]string{
+		"/":       `root`,
+		"/a":      `page a`,
+		"/b":      `page b`,
+		"/c":      `page c`,
+		"/d":      `page d`,
+		"/s1.xml": `<urlset><url><loc>{ts}/b</loc><priority>0.8</priority></url></urlset>`,
+		"/index.xml": `<sitemapindex>
+  <sitemap><loc>{ts}/s1.xml</loc></sitemap>
+  <sitemap><loc>{ts}/s2.xml.gz</loc></sitemap>
+  <sitemap><loc>{ts}/index.xml</loc></sitemap>
+  <sitemap><loc>{ts}/private.xml</loc></sitemap>
+</sitemapindex>`,
+		"/private.xml": `<urlset><url><loc>{ts}/private</loc></url></urlset>`,
+		"/broken.xml":  `<urlset><url><loc>{ts}/d</loc></url><url><loc>{ts}/`,
+		"/sitemap.xml": `<urlset><url><loc>{ts}/</loc></url></urlset>`,
+	}
+	ts := newSiteServer(pages, 0)
+	defer ts.Close()
+	for path, page := range pages {
+		pages[path] = strings.ReplaceAll(page, "{ts}", ts.URL)
+	}
+	pages["/s2.xml.gz"] = gzipped(`<urlset><url><loc>` + ts.URL + `/c</loc></url><url><loc>` + ts.URL + `/a</loc></url></urlset>`)
+	var otherRequests int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&otherRequests, 1)
+		_, _ = fmt.Fprint(w, `<urlset><url><loc>`+ts.URL+`/other</loc></url></urlset>`)
+	}))
+	defer other.Close()
+	pages["/robots.txt"] = "User-agent: *\nDisallow: /c\nDisallow: /private\nSitemap: " + ts.URL + "/index.xml\nSitemap: " + ts.URL + "/missing.xml\n" +
+		"Sitemap: " + ts.URL + "/broken.xml\nSitemap: " + other.URL + "/sitemap.xml\n"
+
+	robots := NewRobots(http.Client{}, "crawler", time.Hour)
+	got := NewSitemaps(http.Client{}, robots, 0).URLs(context.Background(), URL(ts.URL+"/a"))
+	urls := make([]URL, len(got))
+	for i, u := range got {
+		urls[i] = u.URL
+	}
+	// карта с ошибкой отдает прочитанное до нее, запрещенная и чужая не читаются
+	assert.Equal(t, []URL{URL(ts.URL + "/d"), URL(ts.URL + "/"), URL(ts.URL + "/b"), URL(ts.URL + "/c"), URL(ts.URL + "/a")}, urls)
+	if assert.Len(t, got, 5) {
+		assert.Equal(t, URL(ts.URL+"/s1.xml"), got[2].Sitemap)
+		assert.Equal(t, 0.8, got[2].Priority)
+	}
+	assert.Zero(t, atomic.LoadInt32(&otherRequests), "sitemap of another host")
+
+	got = NewSitemaps(http.Client{}, nil, 2).URLs(context.Background(), URL(ts.URL))
+	assert.Equal(t, []SitemapURL{{URL: URL(ts.URL + "/"), Priority: 0.5, Sitemap: URL(ts.URL + "/sitemap.xml")}}, got)
+	got = NewSitemaps(http.Client{}, robots, 2).URLs(context.Background(), URL(ts.URL))
+	assert.Len(t, got, 2, "limit")
+
+	// урлы из карты сайта, кроме запрещенных robots.txt, становятся сидами
+	w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}), 2, 100, MetricMock{}, nil)
+	p := New(w, MetricMock{}, WithRobots(robots), WithSitemaps(NewSitemaps(http.Client{}, robots, 0)), WithMaxDepth(0),
+		WithPriority(SitemapPriority(BreadthFirst)))
+	summary, err := p.Walk(context.Background(), []URL{URL(ts.URL + "/")})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, summary.Fetched, "/, /a, /b and /d")
+	assert.Equal(t, 1, summary.Duplicates, "/ in the sitemap")
+}
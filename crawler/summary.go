@@ -0,0 +1,93 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+var ErrSeedsFailed = errors.New("all seeds failed")
+
+type FailureClass string
+
+const (
+	FailureCanceled       FailureClass = "canceled"
+	FailureTimeout        FailureClass = "timeout"
+	FailureNetwork        FailureClass = "network error"
+	FailureInvalidRequest FailureClass = "invalid request"
+	FailureClientError    FailureClass = "client error"
+	FailureServerError    FailureClass = "server error"
+)
+
+// Summary describes a finished crawl.
+type Summary struct {
+	Fetched  int
+	Failures map[FailureClass]int
+	// Skipped are responses with a body skipped by the ContentPolicy,
+	// it is nil when there are none.
+	Skipped    map[SkipReason]int
+	Duplicates int
+	Bytes      int64
+	Duration   time.Duration
+	// BloomFPRate is a false positive rate of the duplicate filter
+	// for the number of urls it holds at the end of the crawl.
+	BloomFPRate float64
+}
+
+func (s Summary) Failed() int {
+	failed := 0
+	for _, n := range s.Failures {
+		failed += n
+	}
+	return failed
+}
+
+func (s Summary) String() string {
+	skipped := 0
+	for _, n := range s.Skipped {
+		skipped += n
+	}
+	return fmt.Sprintf("fetched: %d, failed: %d %v, skipped: %d %v, duplicates: %d, bytes: %d, duration: %v, bloom fp rate: %g",
+		s.Fetched, s.Failed(), s.Failures, skipped, s.Skipped, s.Duplicates, s.Bytes, s.Duration, s.BloomFPRate)
+}
+
+// classify returns a failure class of the result or false for a fetched page.
+func classify(r Result) (FailureClass, bool) {
+	switch {
+	case errors.Is(r.Err, ErrTimeout):
+		return FailureTimeout, true
+	case errors.Is(r.Err, ErrNetwork):
+		return FailureNetwork, true
+	case errors.Is(r.Err, ErrInvalidRequest):
+		return FailureInvalidRequest, true
+	case r.Err != nil, r.StatusCode == 0:
+		return FailureCanceled, true
+	case r.StatusCode >= 500:
+		return FailureServerError, true
+	case r.StatusCode >= 400:
+		return FailureClientError, true
+	}
+	return "", false
+}
+
+// bloomFPRate is (1 - e^(-kn/m))^k for a filter of m bits, k hashes and n elements.
+func bloomFPRate(m, k, n uint) float64 {
+	if m == 0 {
+		return 0
+	}
+	return math.Pow(1-math.Exp(-float64(k)*float64(n)/float64(m)), float64(k))
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,80 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings of a request. Total is measured from the first attempt
+// to the response headers of the last one.
+type Timings struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+	Total   time.Duration
+}
+
+// tracer collects Timings of one attempt. Transport may call hooks
+// from its own goroutines, so they are guarded by mu.
+type tracer struct {
+	mu       sync.Mutex
+	timings  Timings
+	start    time.Time
+	dnsStart time.Time
+	conStart time.Time
+	tlsStart time.Time
+}
+
+func (t *tracer) trace(req *http.Request) *http.Request {
+	t.mu.Lock()
+	t.timings, t.start = Timings{}, time.Now()
+	t.mu.Unlock()
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.since(&t.timings.DNS, &t.dnsStart)
+		},
+		ConnectStart: func(_, _ string) {
+			t.mark(&t.conStart)
+		},
+		ConnectDone: func(_, _ string, _ error) {
+			t.since(&t.timings.Connect, &t.conStart)
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.since(&t.timings.TLS, &t.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			t.since(&t.timings.TTFB, &t.start)
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+func (t *tracer) mark(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+func (t *tracer) since(d *time.Duration, at *time.Time) {
+	t.mu.Lock()
+	*d = time.Since(*at)
+	t.mu.Unlock()
+}
+
+func (t *tracer) result(began time.Time) Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := t.timings
+	timings.Total = time.Since(began)
+	return timings
+}
@@ -0,0 +1,243 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const warcVersion = "WARC/1.1"
+
+// warcWriter is a Sink writing request and response records of fetched
+// pages to WARC/1.1 files. Every record is a separate gzip member, so
+// a file can be read from any record. A new file is started when the
+// current one grows over maxSize.
+//
+// http.Client decodes gzip bodies transparently, such a response is
+// stored decoded without Content-Encoding and Content-Length headers.
+type warcWriter struct {
+	dir     string
+	prefix  string
+	maxSize int64
+	mu      sync.Mutex
+	file    *os.File
+	w       *bufio.Writer
+	size    int64
+	serial  int
+}
+
+// NewWARCWriter writes files <prefix>-<time>-<serial>.warc.gz to dir.
+// maxSize <= 0 disables rotation.
+func NewWARCWriter(dir, prefix string, maxSize int64) (*warcWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &warcWriter{dir: dir, prefix: prefix, maxSize: maxSize}, nil
+}
+
+// Write stores a page that got a response. Failed pages, including ones
+// whose body was cut by an error, pages marked noindex and pages whose body
+// was skipped by the ContentPolicy are not stored, a truncated body
+// is marked with WARC-Truncated.
+func (w *warcWriter) Write(page Page) error {
+	if page.StatusCode == 0 || page.Request == nil || page.Err != nil || page.Skipped != "" || page.Robots.NoIndex {
+		return nil
+	}
+	now := time.Now().UTC()
+	response := warcRecord{
+		kind:        "response",
+		id:          warcRecordID(),
+		date:        now,
+		target:      page.FinalURL,
+		contentType: "application/http;msgtype=response",
+		block:       warcResponseBlock(page),
+		payload:     page.Content,
+	}
+	if page.Truncated {
+		response.truncated = "length"
+	}
+	request := warcRecord{
+		kind:         "request",
+		id:           warcRecordID(),
+		date:         now,
+		target:       page.FinalURL,
+		contentType:  "application/http;msgtype=request",
+		block:        warcRequestBlock(page),
+		concurrentTo: response.id,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.rotate(); err != nil {
+		return err
+	}
+	for _, record := range []warcRecord{request, response} {
+		if err := w.writeRecord(record); err != nil {
+			return err
+		}
+	}
+	return w.w.Flush()
+}
+
+// Close flushes and closes the current file.
+func (w *warcWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closeFile()
+}
+
+func (w *warcWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.w.Flush()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file, w.w, w.size = nil, nil, 0
+	return err
+}
+
+// rotate opens a new file when there is none or the current one is full.
+func (w *warcWriter) rotate() error {
+	if w.file != nil && (w.maxSize <= 0 || w.size < w.maxSize) {
+		return nil
+	}
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+	w.serial++
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.prefix, time.Now().UTC().Format("20060102150405"), w.serial)
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file, w.w = f, bufio.NewWriter(f)
+	return w.writeRecord(warcRecord{
+		kind:        "warcinfo",
+		id:          warcRecordID(),
+		date:        time.Now().UTC(),
+		filename:    name,
+		contentType: "application/warc-fields",
+		block:       []byte("software: crawler\r\nformat: WARC File Format 1.1\r\n"),
+	})
+}
+
+func (w *warcWriter) writeRecord(r warcRecord) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(r.bytes()); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	n, err := w.w.Write(buf.Bytes())
+	w.size += int64(n)
+	return err
+}
+
+type warcRecord struct {
+	kind         string
+	id           string
+	date         time.Time
+	target       URL
+	filename     string
+	contentType  string
+	concurrentTo string
+	truncated    string
+	block        []byte
+	payload      []byte
+}
+
+func (r warcRecord) bytes() []byte {
+	var b bytes.Buffer
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+		}
+	}
+	b.WriteString(warcVersion + "\r\n")
+	field("WARC-Type", r.kind)
+	field("WARC-Record-ID", r.id)
+	field("WARC-Date", r.date.Format(time.RFC3339Nano))
+	field("WARC-Target-URI", r.target.String())
+	field("WARC-Filename", r.filename)
+	field("WARC-Concurrent-To", r.concurrentTo)
+	field("WARC-Truncated", r.truncated)
+	field("Content-Type", r.contentType)
+	field("WARC-Block-Digest", warcDigest(r.block))
+	if r.payload != nil {
+		field("WARC-Payload-Digest", warcDigest(r.payload))
+	}
+	field("Content-Length", fmt.Sprint(len(r.block)))
+	b.WriteString("\r\n")
+	b.Write(r.block)
+	b.WriteString("\r\n\r\n")
+	return b.Bytes()
+}
+
+// warcResponseBlock is the status line, headers and body of the response.
+// Content-Length of a truncated body is dropped as it doesn't match the body.
+func warcResponseBlock(page Page) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s\r\n", warcProto(page.Proto), page.Status)
+	header := page.Header
+	if page.Truncated {
+		header = header.Clone()
+		header.Del("Content-Length")
+	}
+	_ = header.Write(&b)
+	b.WriteString("\r\n")
+	b.Write(page.Content)
+	return b.Bytes()
+}
+
+// warcRequestBlock is the request line and headers of the final request.
+func warcRequestBlock(page Page) []byte {
+	req := page.Request
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s %s\r\n", req.Method, req.URL.RequestURI(), warcProto(page.Proto))
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	fmt.Fprintf(&b, "Host: %s\r\n", host)
+	header := req.Header
+	if header == nil {
+		header = http.Header{}
+	}
+	_ = header.Write(&b)
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+func warcProto(proto string) string {
+	if proto == "" {
+		return "HTTP/1.1"
+	}
+	return proto
+}
+
+func warcDigest(b []byte) string {
+	sum := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// warcRecordID is a random UUID.
+func warcRecordID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
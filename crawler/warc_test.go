@@ -0,0 +1,197 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type warcTestRecord struct {
+	fields map[string]string
+	block  string
+}
+
+// readWARC reads every record of the file, checking each is a separate gzip member.
+func readWARC(t *testing.T, path string) []warcTestRecord {
+	f, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	records := make([]warcTestRecord, 0)
+	for {
+		if _, err := br.Peek(1); err == io.EOF {
+			return records
+		}
+		gz, err := gzip.NewReader(br)
+		if !assert.NoError(t, err) {
+			return records
+		}
+		gz.Multistream(false)
+		r := bufio.NewReader(gz)
+		version, _ := r.ReadString('\n')
+		assert.Equal(t, "WARC/1.1\r\n", version)
+		record := warcTestRecord{fields: make(map[string]string)}
+		for {
+			line, err := r.ReadString('\n')
+			if !assert.NoError(t, err) || line == "\r\n" {
+				break
+			}
+			name, value, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ": ")
+			record.fields[name] = value
+		}
+		length, _ := strconv.Atoi(record.fields["Content-Length"])
+		block := make([]byte, length)
+		_, err = io.ReadFull(r, block)
+		assert.NoError(t, err)
+		record.block = string(block)
+		rest, _ := io.ReadAll(r)
+		assert.Equal(t, "\r\n\r\n", string(rest), "end of record")
+		assert.NoError(t, gz.Close())
+		records = append(records, record)
+	}
+}
+
+func TestWARCWriter(t *testing.T) {
+	ts := newSiteServer(map[string]string{
+		"/":  `<a href="/a">a</a><a href="/missing">?</a>`,
+		"/a": `page a`,
+	}, 0)
+	defer ts.Close()
+
+	dir := t.TempDir()
+	warc, err := NewWARCWriter(dir, "crawl", 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w := NewWorkerV2(WorkerHandler(http.Client{}, MetricMock{}), 2, 100, MetricMock{}, nil)
+	p := New(w, MetricMock{}, WithSinks(warc))
+	got, err := p.Walk(context.Background(), []URL{URL(ts.URL)})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got.Fetched)
+	assert.NoError(t, warc.Close())
+
+	files, _ := filepath.Glob(filepath.Join(dir, "crawl-*.warc.gz"))
+	assert.Len(t, files, 3, "a file per page with rotation after every write")
+
+	responses := make(map[string]string)
+	for _, file := range files {
+		records := readWARC(t, file)
+		if !assert.Len(t, records, 3) {
+			continue
+		}
+		info, request, response := records[0], records[1], records[2]
+		assert.Equal(t, "warcinfo", info.fields["WARC-Type"])
+		assert.Equal(t, filepath.Base(file), info.fields["WARC-Filename"])
+
+		assert.Equal(t, "request", request.fields["WARC-Type"])
+		assert.Equal(t, "application/http;msgtype=request", request.fields["Content-Type"])
+		assert.Equal(t, response.fields["WARC-Record-ID"], request.fields["WARC-Concurrent-To"])
+		assert.True(t, strings.HasPrefix(request.block, "GET /"), request.block)
+
+		assert.Equal(t, "response", response.fields["WARC-Type"])
+		assert.Equal(t, "application/http;msgtype=response", response.fields["Content-Type"])
+		assert.Equal(t, warcDigest([]byte(response.block)), response.fields["WARC-Block-Digest"])
+		_, body, _ := strings.Cut(response.block, "\r\n\r\n")
+		assert.Equal(t, warcDigest([]byte(body)), response.fields["WARC-Payload-Digest"])
+		responses[response.fields["WARC-Target-URI"]] = response.block
+	}
+	assert.Len(t, responses, 3)
+	assert.True(t, strings.HasPrefix(responses[ts.URL+"/a"], "HTTP/1.1 200 OK\r\n"))
+	assert.True(t, strings.HasSuffix(responses[ts.URL+"/a"], "\r\n\r\npage a"))
+	assert.True(t, strings.HasPrefix(responses[ts.URL+"/missing"], "HTTP/1.1 404 Not Found\r\n"))
+}
+
+func TestWARCWriter_contentPolicy(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://a.ru/page", nil)
+	result := Result{
+		URL: "https://a.ru/page", FinalURL: "https://a.ru/page", Request: req,
+		Status: "200 OK", StatusCode: http.StatusOK, Proto: "HTTP/1.1",
+		Header: http.Header{"Content-Type": {"text/html"}, "Content-Length": {"10"}},
+	}
+	tests := []struct {
+		name      string
+		page      func(p *Page)
+		records   int
+		truncated string
+		header    string
+	}{
+		{
+			name:    "целиком",
+			page:    func(p *Page) { p.Content = []byte("0123456789") },
+			records: 3,
+			header:  "Content-Length: 10\r\n",
+		},
+		{
+			name: "обрезана",
+			page: func(p *Page) {
+				p.Content, p.Truncated = []byte("01234"), true
+			},
+			records:   3,
+			truncated: "length",
+		},
+		{
+			name:    "пропущена по типу",
+			page:    func(p *Page) { p.Skipped = SkipContentType },
+			records: 0,
+		},
+		{
+			name:    "noindex",
+			page:    func(p *Page) { p.Content, p.Robots = []byte("0123456789"), RobotsDirectives{NoIndex: true} },
+			records: 0,
+		},
+		{
+			name: "пропущена после HEAD",
+			page: func(p *Page) {
+				p.Request, _ = http.NewRequest(http.MethodHead, "https://a.ru/page", nil)
+				p.Skipped = SkipTooLarge
+			},
+			records: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			warc, err := NewWARCWriter(dir, "crawl", 0)
+			if !assert.NoError(t, err) {
+				return
+			}
+			page := Page{Result: result}
+			tt.page(&page)
+			assert.NoError(t, warc.Write(page))
+			assert.NoError(t, warc.Close())
+
+			files, _ := filepath.Glob(filepath.Join(dir, "crawl-*.warc.gz"))
+			if tt.records == 0 {
+				assert.Empty(t, files)
+				return
+			}
+			if !assert.Len(t, files, 1) {
+				return
+			}
+			records := readWARC(t, files[0])
+			if !assert.Len(t, records, tt.records) {
+				return
+			}
+			response := records[2]
+			assert.Equal(t, tt.truncated, response.fields["WARC-Truncated"])
+			head, body, _ := strings.Cut(response.block, "\r\n\r\n")
+			assert.Equal(t, string(page.Content), body)
+			if tt.header != "" {
+				assert.Contains(t, head, tt.header)
+			} else {
+				assert.NotContains(t, head, "Content-Length")
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package crawler
 
 import (
 	"context"
-	"fmt"
 	"runtime"
 	"sync"
 	"time"
@@ -12,17 +11,16 @@ import (
 
 type WorkerFunc func(ctx context.Context, url URL) Result
 
-type WaitTask struct {
-	id string
-	*sync.WaitGroup
-}
-
 type worker struct {
 	workFn      WorkerFunc
 	limiter     chan struct{}
 	results     chan Result
-	wait        chan WaitTask
+	tasks       sync.WaitGroup
+	active      chan struct{}
+	mu          sync.Mutex
 	shutdown    bool
+	once        sync.Once
+	done        chan struct{}
 	cancel      context.CancelFunc
 	ctx         context.Context
 	logger      log.Logger
@@ -30,7 +28,12 @@ type worker struct {
 	metrics     Metrics
 }
 
-func NewWorker(workFn WorkerFunc, rateLimit int, timeout time.Duration, execTimeout time.Duration) *worker {
+// NewWorker logs to the logger, nil discards logs. It shuts down
+// gracefully when no task was submitted or done for execTimeout.
+func NewWorker(workFn WorkerFunc, rateLimit int, timeout time.Duration, execTimeout time.Duration, logger log.Logger) *worker {
+	if logger == nil {
+		logger = log.Nop()
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	if timeout > 0 {
 		ctx, cancel = context.WithTimeout(ctx, timeout)
@@ -39,9 +42,10 @@ func NewWorker(workFn WorkerFunc, rateLimit int, timeout time.Duration, execTime
 		workFn:      workFn,
 		limiter:     make(chan struct{}, rateLimit),
 		cancel:      cancel,
-		wait:        make(chan WaitTask),
+		active:      make(chan struct{}, 1),
+		done:        make(chan struct{}),
 		ctx:         ctx,
-		logger:      log.Adapter(log.Printer),
+		logger:      logger,
 		results:     make(chan Result, rateLimit),
 		execTimeout: execTimeout,
 	}
@@ -49,31 +53,47 @@ func NewWorker(workFn WorkerFunc, rateLimit int, timeout time.Duration, execTime
 	return pool
 }
 
+// Shutdown cancels running tasks and closes results.
 func (p *worker) Shutdown() {
-	p.shutdown = true
 	p.cancel()
+	p.GracefulShutdown()
 }
 
+// GracefulShutdown stops accepting tasks, waits for the submitted ones
+// and closes results. It is safe to call it more than once.
 func (p *worker) GracefulShutdown() {
-	p.shutdown = true
+	p.once.Do(func() {
+		p.mu.Lock()
+		p.shutdown = true
+		p.mu.Unlock()
+		close(p.done)
+		p.logger.Debug("await while a works was completed")
+		p.tasks.Wait()
+		close(p.results)
+		p.cancel()
+	})
 }
 
-func (p *worker) SubmitTasks(urls []URL) <-chan Result {
-	if len(urls) == 0 {
+func (p *worker) SubmitTasks(tasks []Task) <-chan Result {
+	if len(tasks) == 0 {
 		return p.results
 	}
+	p.mu.Lock()
 	if p.shutdown {
-		p.logger.Log("not submitted. Await shutdown")
+		p.mu.Unlock()
+		p.logger.Warn("not submitted, await shutdown", log.F("tasks", len(tasks)))
 		return p.results
 	}
-	wg := WaitTask{id: URLs(urls).hash(), WaitGroup: new(sync.WaitGroup)}
-	wg.Add(len(urls))
-	for i, url := range urls {
-		task := url
+	p.tasks.Add(len(tasks))
+	p.mu.Unlock()
+	signal(p.active)
+	for i, t := range tasks {
+		task := t
 		th := i
-		go func(url URL, th int) {
+		go func(task Task, th int) {
 			defer func() {
-				wg.Done()
+				p.tasks.Done()
+				signal(p.active)
 			}()
 
 			for {
@@ -82,7 +102,8 @@ func (p *worker) SubmitTasks(urls []URL) <-chan Result {
 					p.log("cancelled", th, task)
 					return
 				case p.limiter <- struct{}{}:
-					r := p.workFn(p.ctx, task)
+					r := p.workFn(p.ctx, task.URL)
+					r.Depth, r.Parent = task.Depth, task.Parent
 					<-p.limiter
 					p.log("retrieve result", th, task)
 					for {
@@ -105,63 +126,30 @@ func (p *worker) SubmitTasks(urls []URL) <-chan Result {
 			}
 		}(task, th)
 	}
-	go func() {
-		p.logger.Log(fmt.Sprintf("submit works %s", wg.id))
-		for {
-			if p.ctx.Err() != nil {
-				return
-			}
-			select {
-			case <-p.ctx.Done():
-				return
-			case p.wait <- wg:
-				return
-			default:
-				runtime.Gosched()
-			}
-		}
-	}()
 	return p.results
 }
 
+// waitComplete shuts the worker down when it is idle for execTimeout.
 func (p *worker) waitComplete() {
-	t := time.NewTicker(p.execTimeout)
+	t := time.NewTimer(p.execTimeout)
 	defer t.Stop()
-
-	stop := make(chan struct{})
-
-	go func() {
-		<-t.C
-		stop <- struct{}{}
-		p.logger.Log("read timeout")
-		return
-	}()
-
 	for {
 		select {
-		case wg := <-p.wait:
+		case <-p.active:
+			if !t.Stop() {
+				<-t.C
+			}
 			t.Reset(p.execTimeout)
-			wg.Wait()
-			p.logger.Log(fmt.Sprintf("complete of works %s", wg.id))
-			continue
-		case <-stop:
-			p.logger.Log("stop by read timeout")
-			goto END
+		case <-t.C:
+			p.logger.Info("stop by read timeout")
+			p.GracefulShutdown()
+			return
+		case <-p.done:
+			return
 		}
 	}
-END:
-	close(p.wait)
-	p.logger.Log("await while a works was completed")
-	for wg := range p.wait {
-		wg.Wait()
-		p.logger.Log(fmt.Sprintf("complete of works %s", wg.id))
-	}
-	close(p.results)
 }
 
-func (p *worker) log(message string, th int, task URL) {
-	if p.logger == nil {
-		return
-	}
-	p.logger.Log(fmt.Sprintf("th %d: %s for task %s", th, message, task))
+func (p *worker) log(message string, th int, task Task) {
+	p.logger.Debug(message, log.F("th", th), log.F("url", task.URL), log.F("depth", task.Depth))
 }
@@ -9,8 +9,8 @@ import (
 
 func Test_PoolV2Shutdown(t *testing.T) {
 	actual := make([]Result, 0)
-	pool := NewWorkerV2(mockWorkFn(100*time.Millisecond), 0, 0, 500*time.Millisecond, MetricMock{})
-	out := pool.SubmitTasks([]URL{"https://example.com", "https://google.com"})
+	pool := NewWorkerV2(mockWorkFn(100*time.Millisecond), 0, 2, MetricMock{}, nil)
+	out := pool.SubmitTasks(URLs{"https://example.com", "https://google.com"}.Tasks(0))
 	pool.Shutdown()
 	assert.Eventuallyf(t, func() bool {
 		if r, ok := <-out; ok {
@@ -59,7 +59,7 @@ func Test_PoolV2SubmitTasks(t *testing.T) {
 				urls:     []URL{"https://yandex.ru", "https://google.com", "https://example.com"},
 				poolSize: 1,
 			},
-			want: []Result{ResultOK, ResultOK, ResultOK},
+			want: []Result{resultOK, resultOK, resultOK},
 		},
 		{
 			name: "успешное выполнение с параллельном режиме",
@@ -68,7 +68,7 @@ func Test_PoolV2SubmitTasks(t *testing.T) {
 				urls:     []URL{"https://yandex.ru", "https://google.com", "https://example.com"},
 				poolSize: 2,
 			},
-			want: []Result{ResultOK, ResultOK, ResultOK},
+			want: []Result{resultOK, resultOK, resultOK},
 		},
 		{
 			name: "сработал таймаут ожидания с очередью на выполнение",
@@ -78,7 +78,7 @@ func Test_PoolV2SubmitTasks(t *testing.T) {
 				urls:     []URL{"https://yandex.ru", "https://google.com", "https://example.com"},
 				poolSize: 1,
 			},
-			want: []Result{ResultOK, ResultCANCEL},
+			want: []Result{resultOK, resultCANCEL},
 		},
 		{
 			name: "сработал таймаут ожидания",
@@ -88,7 +88,7 @@ func Test_PoolV2SubmitTasks(t *testing.T) {
 				urls:     []URL{"https://yandex.ru", "https://google.com"},
 				poolSize: 4,
 			},
-			want: []Result{ResultCANCEL, ResultCANCEL},
+			want: []Result{resultCANCEL, resultCANCEL},
 		},
 	}
 
@@ -97,8 +97,12 @@ func Test_PoolV2SubmitTasks(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			actual := make([]Result, 0)
-			pool := NewWorkerV2(mockWorkFn(tt.args.execTime), tt.args.poolSize, tt.args.timeout, 100*time.Millisecond, MetricMock{})
-			out := pool.SubmitTasks(tt.args.urls)
+			pool := NewWorkerV2(mockWorkFn(tt.args.execTime), tt.args.poolSize, len(tt.args.urls), MetricMock{}, nil)
+			if tt.args.timeout > 0 {
+				time.AfterFunc(tt.args.timeout, pool.Shutdown)
+			}
+			out := pool.SubmitTasks(URLs(tt.args.urls).Tasks(0))
+			go pool.GracefulShutdown()
 			assert.Eventuallyf(t, func() bool {
 				if r, ok := <-out; ok {
 					actual = append(actual, r)
@@ -112,3 +116,34 @@ func Test_PoolV2SubmitTasks(t *testing.T) {
 	}
 
 }
+
+func Test_PoolV2TaskDepth(t *testing.T) {
+	pool := NewWorkerV2(mockWorkFn(time.Millisecond), 2, 2, MetricMock{}, nil)
+	out := pool.SubmitTasks([]Task{{URL: "https://example.com", Depth: 3}, {URL: "https://google.com", Depth: 3}})
+	for i := 0; i < 2; i++ {
+		r := <-out
+		assert.Equal(t, 3, r.Depth, "result depth")
+	}
+	pool.Shutdown()
+}
+
+func Test_PoolV2Backpressure(t *testing.T) {
+	pool := NewWorkerV2(mockWorkFn(time.Millisecond), 1, 1, MetricMock{}, nil)
+	defer pool.Shutdown()
+	submitted := make(chan struct{})
+	go func() {
+		pool.SubmitTasks(URLs(generateURLs(4)).Tasks(0))
+		close(submitted)
+	}()
+	out := pool.SubmitTasks(nil)
+	<-out
+	select {
+	case <-submitted:
+		t.Fatal("tasks are submitted while the queue is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	for i := 0; i < 3; i++ {
+		<-out
+	}
+	<-submitted
+}
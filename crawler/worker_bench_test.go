@@ -3,7 +3,12 @@ package crawler
 import (
 	"context"
 	"fmt"
+	"io"
 	"math/rand"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -28,17 +33,60 @@ func generateURLs(n int) []URL {
 
 func BenchmarkWorker(b *testing.B) {
 
-	pool := NewWorker(MockWorkFn, 100, 0, time.Second)
-	pool.logger = nil
-	urls := generateURLs(100)
+	pool := NewWorker(MockWorkFn, 100, 0, time.Second, nil)
+	tasks := URLs(generateURLs(100)).Tasks(0)
 	var o Result
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		out := pool.SubmitTasks(urls)
-		for o = range out {
-			if o.Status != "OK" {
+		out := pool.SubmitTasks(tasks)
+		for range tasks {
+			if o = <-out; o.Status != "OK" {
 				b.Fail()
 			}
 		}
 	}
 }
+
+func BenchmarkWorkerV2(b *testing.B) {
+
+	pool := NewWorkerV2(MockWorkFn, 100, 100, MetricMock{}, nil)
+	defer pool.Shutdown()
+	tasks := URLs(generateURLs(100)).Tasks(0)
+	var o Result
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		out := pool.SubmitTasks(tasks)
+		for range tasks {
+			if o = <-out; o.Status != "OK" {
+				b.Fail()
+			}
+		}
+	}
+}
+
+// BenchmarkWalkFanOut crawls pages of 50 links each to the depth of 2.
+// The goroutines of the walk stay bounded by the pool and the handlers
+// while pages have more links than the worker takes.
+func BenchmarkWalkFanOut(b *testing.B) {
+	var goroutines int64
+	fetch := func(ctx context.Context, url URL) Result {
+		if n := int64(runtime.NumGoroutine()); n > atomic.LoadInt64(&goroutines) {
+			atomic.StoreInt64(&goroutines, n)
+		}
+		var page strings.Builder
+		for i := 0; i < 50; i++ {
+			fmt.Fprintf(&page, `<a href="%s/%d">%d</a>`, url, i, i)
+		}
+		return Result{URL: url, FinalURL: url, StatusCode: http.StatusOK, Status: "OK", Body: io.NopCloser(strings.NewReader(page.String()))}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pool := NewWorkerV2(fetch, 100, 100, MetricMock{}, nil)
+		got, err := New(pool, MetricMock{}, WithMaxDepth(2)).Walk(context.Background(), []URL{"https://example.com"})
+		if err != nil || got.Fetched != 1+50+50*50 {
+			b.Fatal(got, err)
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&goroutines)), "goroutines")
+}
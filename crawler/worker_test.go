@@ -2,27 +2,34 @@ package crawler
 
 import (
 	"context"
+	"net/http"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+var (
+	resultOK     = Result{Status: "200 OK", StatusCode: 200, Body: http.NoBody}
+	resultFAIL   = Result{Status: "500 Internal Server Error", StatusCode: 500, Body: http.NoBody}
+	resultCANCEL = NewFailedResult("", &FetchError{Kind: ErrCanceled, Err: context.Canceled})
+)
+
 func mockWorkFn(execTime time.Duration) WorkerFunc {
 	return func(ctx context.Context, url URL) Result {
 		select {
 		case <-time.After(execTime):
-			return ResultOK
+			return resultOK
 		case <-ctx.Done():
-			return ResultCANCEL
+			return resultCANCEL
 		}
 	}
 }
 
 func Test_PoolShutdown(t *testing.T) {
 	actual := make([]Result, 0)
-	pool := NewWorker(mockWorkFn(100*time.Millisecond), 0, 0, 500*time.Millisecond)
-	out := pool.SubmitTasks([]URL{"https://example.com", "https://google.com"})
+	pool := NewWorker(mockWorkFn(100*time.Millisecond), 0, 0, 500*time.Millisecond, nil)
+	out := pool.SubmitTasks(URLs{"https://example.com", "https://google.com"}.Tasks(0))
 	pool.Shutdown()
 	assert.Eventuallyf(t, func() bool {
 		if r, ok := <-out; ok {
@@ -71,7 +78,7 @@ func Test_PoolSubmitTasks(t *testing.T) {
 				urls:     []URL{"https://yandex.ru", "https://google.com", "https://example.com"},
 				poolSize: 1,
 			},
-			want: []Result{ResultOK, ResultOK, ResultOK},
+			want: []Result{resultOK, resultOK, resultOK},
 		},
 		{
 			name: "успешное выполнение с параллельном режиме",
@@ -80,7 +87,7 @@ func Test_PoolSubmitTasks(t *testing.T) {
 				urls:     []URL{"https://yandex.ru", "https://google.com", "https://example.com"},
 				poolSize: 2,
 			},
-			want: []Result{ResultOK, ResultOK, ResultOK},
+			want: []Result{resultOK, resultOK, resultOK},
 		},
 		{
 			name: "сработал таймаут ожидания с очередью на выполнение",
@@ -90,7 +97,7 @@ func Test_PoolSubmitTasks(t *testing.T) {
 				urls:     []URL{"https://yandex.ru", "https://google.com", "https://example.com"},
 				poolSize: 1,
 			},
-			want: []Result{ResultOK},
+			want: []Result{resultOK},
 		},
 		{
 			name: "сработал таймаут ожидания",
@@ -109,8 +116,8 @@ func Test_PoolSubmitTasks(t *testing.T) {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
 			actual := make([]Result, 0)
-			pool := NewWorker(mockWorkFn(tt.args.execTime), tt.args.poolSize, tt.args.timeout, 100*time.Millisecond)
-			out := pool.SubmitTasks(tt.args.urls)
+			pool := NewWorker(mockWorkFn(tt.args.execTime), tt.args.poolSize, tt.args.timeout, 100*time.Millisecond, nil)
+			out := pool.SubmitTasks(URLs(tt.args.urls).Tasks(0))
 			assert.Eventuallyf(t, func() bool {
 				if r, ok := <-out; ok {
 					actual = append(actual, r)
@@ -124,3 +131,16 @@ func Test_PoolSubmitTasks(t *testing.T) {
 	}
 
 }
+
+func Test_PoolWalkCancel(t *testing.T) {
+	for _, policy := range []ShutdownPolicy{ShutdownAbort, ShutdownDrain} {
+		pool := NewWorker(mockWorkFn(200*time.Millisecond), 1, 0, 10*time.Second, nil)
+		p := New(pool, MetricMock{}, WithShutdownPolicy(policy))
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		started := time.Now()
+		_, err := p.Walk(ctx, []URL{"https://example.com/1", "https://example.com/2", "https://example.com/3"})
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(started), time.Second, "walk is stopped by the context")
+	}
+}
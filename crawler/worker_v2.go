@@ -2,105 +2,140 @@ package crawler
 
 import (
 	"context"
-	"fmt"
-	"runtime"
 	"sync"
-	"time"
 
 	"crawler/log"
 )
 
+// workerV2 is a fixed pool of goroutines fetching tasks from a bounded queue.
 type workerV2 struct {
 	workFn  WorkerFunc
-	limiter chan struct{}
+	queue   chan Task
 	results chan Result
+	started chan struct{}
 	*sync.WaitGroup
-	shutdown    bool
-	cancel      context.CancelFunc
-	ctx         context.Context
-	logger      log.Logger
-	execTimeout time.Duration
-	timer       *time.Timer
-	metrics     Metrics
+	mu       sync.Mutex
+	shutdown bool
+	once     sync.Once
+	cancel   context.CancelFunc
+	ctx      context.Context
+	logger   log.Logger
+	metrics  Metrics
 }
 
-func NewWorkerV2(workFn WorkerFunc, rateLimit int, timeout time.Duration, execTimeout time.Duration, metrics Metrics) *workerV2 {
-	ctx, cancel := context.WithCancel(context.Background())
-	if timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, timeout)
+// NewWorkerV2 starts rateLimit goroutines executing workFn. At most queueSize
+// tasks wait for a free goroutine, SubmitTasks blocks while the queue is full.
+// A nil logger discards logs.
+func NewWorkerV2(workFn WorkerFunc, rateLimit int, queueSize int, metrics Metrics, logger log.Logger) *workerV2 {
+	if logger == nil {
+		logger = log.Nop()
 	}
+	ctx, cancel := context.WithCancel(context.Background())
 	pool := &workerV2{
-		workFn:      workFn,
-		limiter:     make(chan struct{}, rateLimit),
-		cancel:      cancel,
-		WaitGroup:   new(sync.WaitGroup),
-		ctx:         ctx,
-		logger:      log.Adapter(log.Printer),
-		results:     make(chan Result),
-		execTimeout: execTimeout,
-		metrics:     metrics,
+		workFn:    workFn,
+		queue:     make(chan Task, queueSize),
+		started:   make(chan struct{}, 1),
+		cancel:    cancel,
+		WaitGroup: new(sync.WaitGroup),
+		ctx:       ctx,
+		logger:    logger,
+		results:   make(chan Result),
+		metrics:   metrics,
+	}
+	for th := 0; th < rateLimit; th++ {
+		go pool.run(th)
 	}
-	pool.timer = time.AfterFunc(execTimeout, pool.GracefulShutdown)
 	return pool
 }
 
+// Shutdown cancels running tasks, drops the waiting ones and closes results.
 func (p *workerV2) Shutdown() {
 	p.cancel()
+	go p.drain()
 	p.GracefulShutdown()
 }
 
+// GracefulShutdown stops accepting tasks, waits for the submitted ones
+// and closes results. It is safe to call it more than once.
 func (p *workerV2) GracefulShutdown() {
-	p.logger.Log("start graceful shutdown")
-	p.shutdown = true
-	p.logger.Log("wait for pool is complete")
-	p.Wait()
-	close(p.results)
-	close(p.limiter)
-	p.timer.Stop()
-	p.logger.Log("pool was complete")
+	p.once.Do(func() {
+		p.logger.Debug("start graceful shutdown")
+		p.mu.Lock()
+		p.shutdown = true
+		p.mu.Unlock()
+		p.logger.Debug("wait for pool is complete")
+		p.Wait()
+		close(p.queue)
+		close(p.results)
+		p.cancel()
+		p.logger.Debug("pool was complete")
+	})
 }
 
-func (p *workerV2) SubmitTasks(urls []URL) <-chan Result {
-	if len(urls) == 0 {
+// SubmitTasks puts tasks to the queue. It blocks while the queue is full
+// and drops the rest of tasks when the pool is shut down.
+func (p *workerV2) SubmitTasks(tasks []Task) <-chan Result {
+	if len(tasks) == 0 {
 		return p.results
 	}
+	p.mu.Lock()
 	if p.shutdown {
-		p.metrics.IncSkipped(len(urls))
+		p.mu.Unlock()
+		p.metrics.IncSkipped(len(tasks))
 		return p.results
 	}
-	p.Add(len(urls))
-	for i, url := range urls {
-		task := url
-		th := i
-		go func(url URL, th int) {
-			defer func() {
-				p.Done()
-			}()
-
-			p.metrics.IncSubmitted()
-			for p.ctx.Err() == nil {
-				select {
-				case <-p.ctx.Done():
-					p.log("cancelled", th, task)
-					return
-				case p.limiter <- struct{}{}:
-					result := p.workFn(p.ctx, task)
-					<-p.limiter
-					p.results <- result
-					p.metrics.IncProcessed()
-					return
-				default:
-					runtime.Gosched()
-				}
+	p.Add(len(tasks))
+	p.mu.Unlock()
+	for i, task := range tasks {
+		if p.ctx.Err() == nil {
+			select {
+			case p.queue <- task:
+				p.metrics.IncSubmitted()
+				continue
+			case <-p.ctx.Done():
 			}
-		}(task, th)
+		}
+		p.metrics.IncSkipped(len(tasks) - i)
+		p.Add(i - len(tasks))
+		break
 	}
 	return p.results
 }
 
-func (p *workerV2) log(message string, th int, task URL) {
-	if p.logger == nil {
-		return
+// Ready tells whether no task waits in the queue, the pool has no hosts.
+func (p *workerV2) Ready(string) bool {
+	return len(p.queue) == 0
+}
+
+// Wake is signalled when a goroutine takes a task from the queue.
+func (p *workerV2) Wake() <-chan struct{} {
+	return p.started
+}
+
+func (p *workerV2) run(th int) {
+	for task := range p.queue {
+		signal(p.started)
+		if p.ctx.Err() != nil {
+			p.log("cancelled", th, task)
+			p.Done()
+			continue
+		}
+		result := p.workFn(p.ctx, task.URL)
+		result.Depth, result.Parent = task.Depth, task.Parent
+		p.results <- result
+		p.metrics.IncProcessed()
+		p.Done()
+	}
+}
+
+// drain drops the queued tasks of a cancelled pool.
+func (p *workerV2) drain() {
+	for task := range p.queue {
+		p.log("cancelled", -1, task)
+		p.Done()
 	}
-	p.logger.Log(fmt.Sprintf("th %d: %s for task %s", th, message, task))
+}
+
+func (p *workerV2) log(message string, th int, task Task) {
+	p.logger.Debug(message, log.F("th", th), log.F("url", task.URL), log.F("host", task.URL.Host()), log.F("depth", task.Depth))
 }
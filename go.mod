@@ -2,13 +2,15 @@ module crawler
 
 go 1.18
 
-require github.com/stretchr/testify v1.8.0
+require (
+	github.com/bits-and-blooms/bloom/v3 v3.3.0
+	github.com/stretchr/testify v1.8.0
+	golang.org/x/net v0.0.0-20220812174116-3211cb980234
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/bits-and-blooms/bitset v1.3.0 // indirect
-	github.com/bits-and-blooms/bloom/v3 v3.3.0 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	golang.org/x/net v0.0.0-20220812174116-3211cb980234 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,196 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	}
+	return "level(" + strconv.Itoa(int(l)) + ")"
+}
+
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelError; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q", s)
+}
+
+type Format int
+
+const (
+	FormatText Format = iota
+	FormatJSON
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "text":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatText, fmt.Errorf("unknown log format %q", s)
+}
+
+// Field is a key/value pair of a message, e.g. F("url", u).
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger writes messages of its level and above with fields.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+	// With returns a logger adding the fields to every message.
+	With(fields ...Field) Logger
+}
+
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type logger struct {
+	out    *output
+	level  Level
+	format Format
+	fields []Field
+	now    func() time.Time
+}
+
+// New writes a line per message to w. It is safe for concurrent use.
+func New(w io.Writer, level Level, format Format) Logger {
+	return &logger{out: &output{w: w}, level: level, format: format, now: time.Now}
+}
+
+// Nop discards all messages.
+func Nop() Logger {
+	return nop{}
+}
+
+func (l *logger) Debug(msg string, fields ...Field) { l.log(LevelDebug, msg, fields) }
+
+func (l *logger) Info(msg string, fields ...Field) { l.log(LevelInfo, msg, fields) }
+
+func (l *logger) Warn(msg string, fields ...Field) { l.log(LevelWarn, msg, fields) }
+
+func (l *logger) Error(msg string, fields ...Field) { l.log(LevelError, msg, fields) }
+
+func (l *logger) With(fields ...Field) Logger {
+	c := *l
+	c.fields = append(append([]Field(nil), l.fields...), fields...)
+	return &c
+}
+
+func (l *logger) log(level Level, msg string, fields []Field) {
+	if level < l.level {
+		return
+	}
+	all := append(append([]Field(nil), l.fields...), fields...)
+	var b strings.Builder
+	if l.format == FormatJSON {
+		writeJSON(&b, l.now(), level, msg, all)
+	} else {
+		writeText(&b, l.now(), level, msg, all)
+	}
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	_, _ = io.WriteString(l.out.w, b.String())
+}
+
+func writeText(b *strings.Builder, now time.Time, level Level, msg string, fields []Field) {
+	b.WriteString(now.UTC().Format(time.RFC3339Nano))
+	b.WriteByte(' ')
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for _, f := range fields {
+		s := fmt.Sprint(value(f.Value))
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			s = strconv.Quote(s)
+		}
+		b.WriteString(" " + f.Key + "=" + s)
+	}
+	b.WriteByte('\n')
+}
+
+func writeJSON(b *strings.Builder, now time.Time, level Level, msg string, fields []Field) {
+	b.WriteString(`{"time":`)
+	writeJSONValue(b, now.UTC().Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSONValue(b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSONValue(b, msg)
+	for _, f := range fields {
+		b.WriteByte(',')
+		writeJSONValue(b, f.Key)
+		b.WriteByte(':')
+		writeJSONValue(b, value(f.Value))
+	}
+	b.WriteString("}\n")
+}
+
+func writeJSONValue(b *strings.Builder, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
+
+// value makes errors, durations and stringers readable in both formats.
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+type nop struct{}
+
+func (nop) Debug(string, ...Field) {}
+
+func (nop) Info(string, ...Field) {}
+
+func (nop) Warn(string, ...Field) {}
+
+func (nop) Error(string, ...Field) {}
+
+func (n nop) With(...Field) Logger { return n }
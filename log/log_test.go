@@ -0,0 +1,42 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(level Level, format Format) (*logger, *bytes.Buffer) {
+	var b bytes.Buffer
+	l := New(&b, level, format).(*logger)
+	l.now = func() time.Time { return time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC) }
+	return l, &b
+}
+
+func TestLogger_Text(t *testing.T) {
+	l, b := newTestLogger(LevelInfo, FormatText)
+	l.Debug("hidden")
+	l.With(F("url", "https://a.ru/x y")).Info("fetched", F("depth", 2), F("status", 200), F("took", 1500*time.Millisecond))
+	l.Error("failed", F("err", errors.New("no route")), F("host", ""))
+	assert.Equal(t, `2022-08-01T12:00:00Z INFO fetched url="https://a.ru/x y" depth=2 status=200 took=1.5s
+2022-08-01T12:00:00Z ERROR failed err="no route" host=""
+`, b.String())
+}
+
+func TestLogger_JSON(t *testing.T) {
+	l, b := newTestLogger(LevelDebug, FormatJSON)
+	l.With(F("host", "a.ru")).Debug("cancelled", F("url", "https://a.ru/"), F("depth", 1), F("err", errors.New(`"quoted"`)))
+	assert.Equal(t, `{"time":"2022-08-01T12:00:00Z","level":"debug","msg":"cancelled","host":"a.ru","url":"https://a.ru/","depth":1,"err":"\"quoted\""}
+`, b.String())
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := ParseLevel("WARN")
+	assert.NoError(t, err)
+	assert.Equal(t, LevelWarn, level)
+	_, err = ParseLevel("verbose")
+	assert.Error(t, err)
+}